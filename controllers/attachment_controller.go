@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/services"
+	"go-inspect/storage"
+	"go-inspect/utils"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadOrderAttachment 上传工单附件
+func UploadOrderAttachment(c *gin.Context) {
+	uploadAttachment(c, services.AttachmentTarget{})
+}
+
+// UploadCheckAttachment 上传巡检点确认的附件，只有执行人可以在巡检过程中上传
+func UploadCheckAttachment(c *gin.Context) {
+	uploadAttachment(c, services.AttachmentTarget{PointID: utils.StringToUint(c.Param("pointId"))})
+}
+
+// UploadItemAttachment 上传巡检项的附件（如拍照类巡检项的照片），只有执行人可以在巡检过程中上传
+func UploadItemAttachment(c *gin.Context) {
+	uploadAttachment(c, services.AttachmentTarget{
+		PointID: utils.StringToUint(c.Param("pointId")),
+		ItemID:  utils.StringToUint(c.Param("itemId")),
+	})
+}
+
+// uploadAttachment 读取表单中的 file 文件并保存到 target 指定的位置
+func uploadAttachment(c *gin.Context, target services.AttachmentTarget) {
+	order, ok := loadAccessibleOrder(c)
+	if !ok {
+		return
+	}
+	target.OrderID = order.ID
+
+	// 限制请求体大小，为表单字段预留少量空间
+	maxSize := services.MaxAttachmentSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, services.ErrAttachmentTooLarge.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, "请选择要上传的文件")
+		return
+	}
+	if fileHeader.Size > maxSize {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, services.ErrAttachmentTooLarge.Error())
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取上传文件失败")
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "读取上传文件失败")
+		return
+	}
+
+	attachment, err := services.SaveAttachment(target, utils.CurrentUserID(c), fileHeader.Filename, data)
+	if err != nil {
+		respondAttachmentError(c, err, "上传附件失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "上传附件成功", attachment)
+}
+
+// ListOrderAttachments 列出工单及其巡检点、巡检项的附件，附带限时下载地址
+func ListOrderAttachments(c *gin.Context) {
+	order, ok := loadAccessibleOrder(c)
+	if !ok {
+		return
+	}
+
+	var attachments []models.Attachment
+	if err := config.DB.Where("order_id = ?", order.ID).Order("id").Find(&attachments).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取附件列表失败")
+		return
+	}
+	for i := range attachments {
+		services.SignAttachmentURLs(&attachments[i])
+	}
+
+	utils.SuccessResponse(c, "获取附件列表成功", attachments)
+}
+
+// DownloadAttachment 通过限时签名地址下载附件，thumbnail=1 时下载缩略图
+func DownloadAttachment(c *gin.Context) {
+	id := utils.StringToUint(c.Param("id"))
+	thumbnail := c.Query("thumbnail") == "1"
+
+	if !utils.VerifyResourceSignature(services.AttachmentResource(id, thumbnail), c.Query("expires"), c.Query("signature")) {
+		utils.ErrorResponse(c, http.StatusForbidden, "下载地址无效或已过期")
+		return
+	}
+
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "附件不存在")
+		return
+	}
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			utils.ErrorResponse(c, http.StatusNotFound, "附件没有缩略图")
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	reader, err := storage.Default.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "附件文件不存在")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "读取附件失败")
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Disposition", "inline; filename*=UTF-8''"+url.PathEscape(attachment.FileName))
+	c.Header("Cache-Control", "private, max-age=300")
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+// DeleteAttachment 删除附件，只有执行人可以在巡检过程中删除
+func DeleteAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := config.DB.First(&attachment, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "附件不存在")
+		return
+	}
+
+	var order models.InspectionOrder
+	if err := config.DB.Preload("Plan").First(&order, attachment.OrderID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+		return
+	}
+	if !utils.HasProjectAccess(c, order.Plan.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权删除该附件")
+		return
+	}
+
+	if err := services.DeleteAttachment(attachment, utils.CurrentUserID(c)); err != nil {
+		respondAttachmentError(c, err, "删除附件失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "删除附件成功", nil)
+}
+
+// loadAccessibleOrder 读取路径中的工单并检查当前用户是否有权访问
+func loadAccessibleOrder(c *gin.Context) (models.InspectionOrder, bool) {
+	var order models.InspectionOrder
+	if err := config.DB.Preload("Plan").First(&order, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+		return order, false
+	}
+	if !utils.HasProjectAccess(c, order.Plan.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该巡检工单")
+		return order, false
+	}
+	return order, true
+}
+
+// respondAttachmentError 将附件相关的错误转换为统一的响应
+func respondAttachmentError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, services.ErrAttachmentTypeNotAllowed):
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, services.ErrItemNotInPoint):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrOrderCancelled):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		respondOrderError(c, err, msg)
+	}
+}
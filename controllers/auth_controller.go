@@ -3,6 +3,7 @@ package controllers
 import (
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
 	"net/http"
 
@@ -50,6 +51,8 @@ func Register(c *gin.Context) {
 		return
 	}
 	user.Password = string(hashedPassword)
+	// 角色只能由管理员设置
+	user.Role = ""
 
 	if err := config.DB.Create(&user).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "用户注册失败")
@@ -185,3 +188,38 @@ func ChangePassword(c *gin.Context) {
 
 	utils.SuccessResponse(c, "密码修改成功", nil)
 }
+
+// UpdateUserRole 设置用户角色，仅未关联项目的管理员可以操作
+func UpdateUserRole(c *gin.Context) {
+	var operator models.User
+	if err := config.DB.First(&operator, utils.CurrentUserID(c)).Error; err != nil || operator.ProjectID != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权设置用户角色")
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+		return
+	}
+
+	var roleForm struct {
+		Role string `json:"role" binding:"required,max=20"`
+	}
+	if err := c.ShouldBindJSON(&roleForm); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !services.ValidUserRole(roleForm.Role) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户角色")
+		return
+	}
+
+	if err := config.DB.Model(&user).Update("role", roleForm.Role).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "设置用户角色失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "用户角色设置成功", user)
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/services"
+	"go-inspect/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// blackoutDateView 项目生效的停检日，Inherited 表示继承自上级项目
+type blackoutDateView struct {
+	models.BlackoutDate
+	Inherited bool `json:"inherited"`
+}
+
+// ListBlackoutDates 列出项目生效的停检日，包括从上级项目继承的停检日
+func ListBlackoutDates(c *gin.Context) {
+	projectID := utils.StringToUint(c.Param("id"))
+	var project models.Project
+	if err := config.DB.First(&project, projectID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	if !utils.HasProjectAccess(c, project.ID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该项目的日历")
+		return
+	}
+
+	var own []models.BlackoutDate
+	if err := config.DB.Where("project_id = ?", project.ID).Order("date").Find(&own).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取停检日失败")
+		return
+	}
+
+	dates := make([]blackoutDateView, 0, len(own))
+	for _, d := range own {
+		dates = append(dates, blackoutDateView{BlackoutDate: d})
+	}
+
+	// 上级项目中作用于子项目的停检日
+	inherited, err := services.InheritedBlackoutDates(project.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取停检日失败")
+		return
+	}
+	for _, d := range inherited {
+		dates = append(dates, blackoutDateView{BlackoutDate: d, Inherited: true})
+	}
+
+	utils.SuccessResponse(c, "获取停检日成功", dates)
+}
+
+// CreateBlackoutDate 为项目添加停检日
+func CreateBlackoutDate(c *gin.Context) {
+	projectID := utils.StringToUint(c.Param("id"))
+	var project models.Project
+	if err := config.DB.First(&project, projectID).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "项目不存在")
+		return
+	}
+
+	if !utils.HasProjectAccess(c, project.ID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权修改该项目的日历")
+		return
+	}
+
+	var input struct {
+		Date            string `json:"date" binding:"required"`
+		Name            string `json:"name"`
+		ApplyToChildren *bool  `json:"apply_to_children"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := time.Parse(time.DateOnly, input.Date); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "日期格式应为 YYYY-MM-DD")
+		return
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.BlackoutDate{}).Where("project_id = ? AND date = ?", project.ID, input.Date).Count(&count).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询停检日失败")
+		return
+	}
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "该日期已是停检日")
+		return
+	}
+
+	date := models.BlackoutDate{
+		ProjectID:       project.ID,
+		Date:            input.Date,
+		Name:            input.Name,
+		ApplyToChildren: input.ApplyToChildren == nil || *input.ApplyToChildren,
+	}
+	// 并发添加同一日期时由 (project_id, date) 唯一索引拦截
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&date)
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "添加停检日失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "该日期已是停检日")
+		return
+	}
+
+	utils.SuccessResponse(c, "停检日添加成功", date)
+}
+
+// DeleteBlackoutDate 删除项目的停检日
+func DeleteBlackoutDate(c *gin.Context) {
+	var date models.BlackoutDate
+	if err := config.DB.Where("project_id = ?", c.Param("id")).First(&date, c.Param("dateId")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "停检日不存在")
+		return
+	}
+
+	if !utils.HasProjectAccess(c, date.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权修改该项目的日历")
+		return
+	}
+
+	// 直接删除记录，之后可以重新添加同一日期
+	if err := config.DB.Unscoped().Delete(&date).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除停检日失败")
+		return
+	}
+	utils.SuccessResponse(c, "停检日删除成功", nil)
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/services"
+	"go-inspect/utils"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListDefects 列出有权访问的项目中的整改单，支持按状态、严重程度、项目、工单、点位和负责人筛选
+func ListDefects(c *gin.Context) {
+	projectIDs := utils.GetAccessibleProjectIDs(c)
+	if projectID := utils.StringToUint(c.Query("project_id")); projectID != 0 {
+		if !slices.Contains(projectIDs, projectID) {
+			utils.ErrorResponse(c, http.StatusForbidden, "无权访问该项目的整改单")
+			return
+		}
+		projectIDs = []uint{projectID}
+	}
+
+	query := config.DB.Preload("Point").Preload("Item").Preload("Responsible").Where("project_id IN ?", projectIDs)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if orderID := utils.StringToUint(c.Query("order_id")); orderID != 0 {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if pointID := utils.StringToUint(c.Query("point_id")); pointID != 0 {
+		query = query.Where("point_id = ?", pointID)
+	}
+	if responsibleID := utils.StringToUint(c.Query("responsible_id")); responsibleID != 0 {
+		query = query.Where("responsible_id = ?", responsibleID)
+	}
+
+	var defects []models.Defect
+	if err := query.Order("id DESC").Find(&defects).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取整改单列表失败")
+		return
+	}
+	utils.SuccessResponse(c, "获取整改单列表成功", defects)
+}
+
+// GetDefect 获取单个整改单
+func GetDefect(c *gin.Context) {
+	var defect models.Defect
+	if err := config.DB.Preload("Order").Preload("Point").Preload("Item").Preload("Responsible").First(&defect, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "整改单不存在")
+		return
+	}
+
+	if !utils.HasProjectAccess(c, defect.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该整改单")
+		return
+	}
+
+	utils.SuccessResponse(c, "获取整改单成功", defect)
+}
+
+// UpdateDefect 修改整改单的严重程度、负责人和整改期限
+func UpdateDefect(c *gin.Context) {
+	defectID, actor, ok := loadDefectActor(c)
+	if !ok {
+		return
+	}
+
+	var input services.DefectUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	defect, err := services.UpdateDefect(defectID, actor, input)
+	if err != nil {
+		respondDefectError(c, err, "更新整改单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "整改单更新成功", defect)
+}
+
+// StartDefectRepair 开始整改
+func StartDefectRepair(c *gin.Context) {
+	defectID, actor, ok := loadDefectActor(c)
+	if !ok {
+		return
+	}
+
+	defect, err := services.StartDefectRepair(defectID, actor)
+	if err != nil {
+		respondDefectError(c, err, "开始整改失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "已开始整改", defect)
+}
+
+// ResolveDefect 提交整改结果并生成复检工单，复检合格后整改单自动关闭
+func ResolveDefect(c *gin.Context) {
+	defectID, actor, ok := loadDefectActor(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		RepairNote string `json:"repair_note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	defect, err := services.ResolveDefect(defectID, actor, input.RepairNote)
+	if err != nil {
+		respondDefectError(c, err, "提交整改结果失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "整改结果已提交", defect)
+}
+
+// CloseDefect 关闭未整改的整改单（如误报），已整改的整改单由复检结果关闭
+func CloseDefect(c *gin.Context) {
+	defectID, actor, ok := loadDefectActor(c)
+	if !ok {
+		return
+	}
+
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	defect, err := services.CloseDefect(defectID, actor, reason)
+	if err != nil {
+		respondDefectError(c, err, "关闭整改单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "整改单已关闭", defect)
+}
+
+// loadDefectActor 读取路径中的整改单，返回整改单ID和当前用户作为操作人的信息
+func loadDefectActor(c *gin.Context) (uint, services.OrderActor, bool) {
+	var defect models.Defect
+	if err := config.DB.First(&defect, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "整改单不存在")
+		return 0, services.OrderActor{}, false
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, utils.CurrentUserID(c)).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户不存在")
+		return 0, services.OrderActor{}, false
+	}
+
+	actor := services.OrderActor{
+		UserID:        user.ID,
+		UserRole:      user.Role,
+		ProjectMember: utils.HasProjectAccess(c, defect.ProjectID),
+	}
+	return defect.ID, actor, true
+}
+
+// respondDefectError 将整改单操作的错误转换为统一的响应
+func respondDefectError(c *gin.Context, err error, msg string) {
+	var transitionErr *services.DefectTransitionError
+	switch {
+	case errors.As(err, &transitionErr),
+		errors.Is(err, services.ErrDefectClosed):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "整改单不存在")
+	case errors.Is(err, services.ErrDefectActorNotAllowed):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrInvalidSeverity),
+		errors.Is(err, services.ErrAssigneeNotFound):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, msg)
+	}
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
 	"net/http"
 	"strconv"
@@ -18,6 +19,11 @@ func CreateInspectionItem(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateItemDefinition(item); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err := config.DB.Create(&item).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建巡检项失败")
 		return
@@ -51,6 +57,11 @@ func UpdateInspectionItem(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidateItemDefinition(item); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err := config.DB.Save(&item).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检项失败")
 		return
@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ListInspectionOrders 列出巡检工单
@@ -24,22 +28,45 @@ func ListInspectionOrders(c *gin.Context) {
 func GetInspectionOrder(c *gin.Context) {
 	id := c.Param("id")
 	var order models.InspectionOrder
-	if err := config.DB.Preload("Plan").Preload("Assigner").Preload("Assignee").First(&order, id).Error; err != nil {
+	if err := config.DB.Preload("Plan").Preload("Assigner").Preload("Assignee").Preload("RouteVersion").Preload("Checks.Results").First(&order, id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
 		return
 	}
+	// 修改工单时可通过 If-Match 携带该版本号，工单已被他人修改时返回冲突
+	c.Header("ETag", strconv.Quote(strconv.Itoa(order.Version)))
 	utils.SuccessResponse(c, "获取巡检工单成功", order)
 }
 
-// AssignInspectionOrder 分配巡检工单
-func AssignInspectionOrder(c *gin.Context) {
-	id := c.Param("id")
+// GetInspectionOrderTimeline 获取巡检工单的状态变更时间线
+func GetInspectionOrderTimeline(c *gin.Context) {
 	var order models.InspectionOrder
-	if err := config.DB.First(&order, id).Error; err != nil {
+	if err := config.DB.Preload("Plan").First(&order, c.Param("id")).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
 		return
 	}
 
+	// 检查用户是否有权限访问该巡检工单
+	if !utils.HasProjectAccess(c, order.Plan.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该巡检工单")
+		return
+	}
+
+	var events []models.OrderEvent
+	if err := config.DB.Preload("Actor").Where("order_id = ?", order.ID).Order("id").Find(&events).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检工单时间线失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "获取巡检工单时间线成功", events)
+}
+
+// AssignInspectionOrder 分配巡检工单
+func AssignInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+
 	var input struct {
 		AssigneeID uint `json:"assignee_id" binding:"required"`
 	}
@@ -48,124 +75,270 @@ func AssignInspectionOrder(c *gin.Context) {
 		return
 	}
 
-	order.AssigneeID = &input.AssigneeID
-	order.Status = models.OrderStatusAssigned
-	if err := config.DB.Save(&order).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "分配巡检工单失败")
+	order, err := services.AssignOrder(orderID, input.AssigneeID, actor)
+	if err != nil {
+		respondOrderError(c, err, "分配巡检工单失败")
 		return
 	}
 
 	utils.SuccessResponse(c, "巡检工单分配成功", order)
 }
 
-// StartInspectionOrder 开始巡检
-func StartInspectionOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.InspectionOrder
-	if err := config.DB.First(&order, id).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+// ClaimInspectionOrder 认领未分配的巡检工单
+func ClaimInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+
+	order, err := services.ClaimOrder(orderID, actor)
+	if err != nil {
+		respondOrderError(c, err, "认领巡检工单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检工单认领成功", order)
+}
+
+// RejectInspectionOrder 执行人拒绝巡检工单
+func RejectInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
 		return
 	}
 
-	if order.Status != models.OrderStatusAssigned {
-		utils.ErrorResponse(c, http.StatusBadRequest, "巡检工单状态不正确")
+	reason, ok := bindReason(c)
+	if !ok {
 		return
 	}
 
-	now := time.Now()
-	order.Status = models.OrderStatusInProgress
-	order.StartTime = &now
-	if err := config.DB.Save(&order).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "开始巡检失败")
+	order, err := services.RejectOrder(orderID, actor, reason)
+	if err != nil {
+		respondOrderError(c, err, "拒绝巡检工单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检工单已拒绝", order)
+}
+
+// StartInspectionOrder 开始巡检
+func StartInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+
+	order, err := services.StartOrder(orderID, actor)
+	if err != nil {
+		respondOrderError(c, err, "开始巡检失败")
 		return
 	}
 
 	utils.SuccessResponse(c, "巡检已开始", order)
 }
 
-// CheckInspectionPoint 确认巡检点
+// CheckInspectionPoint 确认巡检点，点位有巡检项时需在 items 中逐项填写结果
 func CheckInspectionPoint(c *gin.Context) {
-	orderID := c.Param("id")
-	pointID := c.Param("pointId")
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+	pointID := utils.StringToUint(c.Param("pointId"))
 
-	var order models.InspectionOrder
-	if err := config.DB.First(&order, orderID).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+	var input services.CheckInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if order.Status != models.OrderStatusInProgress {
-		utils.ErrorResponse(c, http.StatusBadRequest, "巡检工单状态不正确")
+	input.ExpectedVersion = actor.ExpectedVersion
+	check, err := services.CheckPoint(orderID, pointID, actor, input)
+	if err != nil {
+		respondOrderError(c, err, "更新巡检点确认失败")
 		return
 	}
 
-	var check models.InspectionPointCheck
-	if err := config.DB.Where("order_id = ? AND point_id = ?", orderID, pointID).First(&check).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "巡检点确认记录不存在")
+	utils.SuccessResponse(c, "巡检点确认成功", check)
+}
+
+// CompleteInspectionOrder 完成巡检，需要审核的计划提交审核后由审核人结单
+func CompleteInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
 		return
 	}
 
 	var input struct {
-		Status  models.CheckStatus `json:"status" binding:"required"`
-		Comment string             `json:"comment"`
+		InspectionData string `json:"inspection_data" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	check.Status = input.Status
-	check.Comment = input.Comment
-	if err := config.DB.Save(&check).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检点确认失败")
+	order, err := services.CompleteOrder(orderID, actor, input.InspectionData)
+	if err != nil {
+		respondOrderError(c, err, "完成巡检失败")
 		return
 	}
 
-	// 更新已完成的巡检点数量
-	order.CompletedChecks++
-	if err := config.DB.Save(&order).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检工单失败")
+	utils.SuccessResponse(c, "巡检已完成", order)
+}
+
+// ApproveInspectionOrder 审核通过巡检工单
+func ApproveInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
 		return
 	}
 
-	utils.SuccessResponse(c, "巡检点确认成功", check)
-}
-
-// CompleteInspectionOrder 完成巡检
-func CompleteInspectionOrder(c *gin.Context) {
-	id := c.Param("id")
-	var order models.InspectionOrder
-	if err := config.DB.First(&order, id).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+	var input struct {
+		Comment string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if order.Status != models.OrderStatusInProgress {
-		utils.ErrorResponse(c, http.StatusBadRequest, "巡检工单状态不正确")
+	order, err := services.ApproveOrder(orderID, actor, input.Comment)
+	if err != nil {
+		respondOrderError(c, err, "审核巡检工单失败")
 		return
 	}
 
-	if order.CompletedChecks != order.TotalChecks {
-		utils.ErrorResponse(c, http.StatusBadRequest, "还有未确认的巡检点")
+	utils.SuccessResponse(c, "巡检工单审核通过", order)
+}
+
+// SendBackInspectionOrder 审核不通过，退回巡检工单返工
+func SendBackInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
 		return
 	}
 
 	var input struct {
-		InspectionData string `json:"inspection_data" binding:"required"`
+		Comment        string `json:"comment" binding:"required,max=500"`
+		ReopenPointIDs []uint `json:"reopen_point_ids"` // 为空时重新巡检所有不合格的点位
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	now := time.Now()
-	order.Status = models.OrderStatusCompleted
-	order.EndTime = &now
-	order.InspectionData = input.InspectionData
-	if err := config.DB.Save(&order).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "完成巡检失败")
+	order, err := services.SendBackOrder(orderID, actor, input.Comment, input.ReopenPointIDs)
+	if err != nil {
+		respondOrderError(c, err, "退回巡检工单失败")
 		return
 	}
 
-	utils.SuccessResponse(c, "巡检已完成", order)
+	utils.SuccessResponse(c, "巡检工单已退回", order)
+}
+
+// ReopenInspectionOrder 重新打开已完成的巡检工单
+func ReopenInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	order, err := services.ReopenOrder(orderID, actor, reason)
+	if err != nil {
+		respondOrderError(c, err, "重新打开巡检工单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检工单已重新打开", order)
+}
+
+// CancelInspectionOrder 取消巡检工单
+func CancelInspectionOrder(c *gin.Context) {
+	orderID, actor, ok := loadOrderActor(c)
+	if !ok {
+		return
+	}
+
+	reason, ok := bindReason(c)
+	if !ok {
+		return
+	}
+
+	order, err := services.CancelOrder(orderID, actor, reason)
+	if err != nil {
+		respondOrderError(c, err, "取消巡检工单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检工单已取消", order)
+}
+
+// loadOrderActor 读取路径中的工单，返回工单ID和当前用户作为操作人的信息
+func loadOrderActor(c *gin.Context) (uint, services.OrderActor, bool) {
+	var order models.InspectionOrder
+	if err := config.DB.Preload("Plan").First(&order, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+		return 0, services.OrderActor{}, false
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, utils.CurrentUserID(c)).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户不存在")
+		return 0, services.OrderActor{}, false
+	}
+
+	actor := services.OrderActor{
+		UserID:          user.ID,
+		UserRole:        user.Role,
+		ProjectMember:   utils.HasProjectAccess(c, order.Plan.ProjectID),
+		ExpectedVersion: ifMatchVersion(c),
+	}
+	return order.ID, actor, true
+}
+
+// ifMatchVersion 读取 If-Match 请求头中客户端持有的工单版本号，未携带时返回 0
+func ifMatchVersion(c *gin.Context) int {
+	version, _ := strconv.Atoi(strings.Trim(strings.TrimPrefix(c.GetHeader("If-Match"), "W/"), `"`))
+	return version
+}
+
+// bindReason 读取请求中必填的原因
+func bindReason(c *gin.Context) (string, bool) {
+	var input struct {
+		Reason string `json:"reason" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	return input.Reason, true
+}
+
+// respondOrderError 将工单状态迁移的错误转换为统一的响应
+func respondOrderError(c *gin.Context, err error, msg string) {
+	var transitionErr *services.OrderTransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检工单不存在")
+	case errors.Is(err, services.ErrOrderActorNotAllowed):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrCheckNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrOrderNotInProgress),
+		errors.Is(err, services.ErrOrderVersionConflict):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrOrderChecksIncomplete),
+		errors.Is(err, services.ErrInvalidCheckInput),
+		errors.Is(err, services.ErrOrderAlreadyClaimed),
+		errors.Is(err, services.ErrAssigneeNotFound),
+		errors.Is(err, services.ErrAssigneeNoProjectAccess):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, msg)
+	}
 }
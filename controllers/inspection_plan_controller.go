@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
+	"log"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 )
 
 // CreateInspectionPlan 创建巡检计划
@@ -26,11 +31,25 @@ func CreateInspectionPlan(c *gin.Context) {
 	}
 
 	// 验证触发类型和触发日
-	if err := validateTrigger(plan.TriggerType, plan.TriggerDay); err != nil {
+	if err := validateTrigger(plan); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// 验证有效期，未指定开始日期时从创建时起生效
+	if err := validatePlanWindow(plan); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validatePlanAssignee(c, plan) {
+		return
+	}
+	if plan.StartDate == nil {
+		now := time.Now()
+		plan.StartDate = &now
+	}
+	plan.Status = models.PlanStatusActive
+
 	if err := config.DB.Create(&plan).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建巡检计划失败")
 		return
@@ -72,16 +91,37 @@ func UpdateInspectionPlan(c *gin.Context) {
 		return
 	}
 
+	if plan.Status == models.PlanStatusArchived {
+		utils.ErrorResponse(c, http.StatusBadRequest, "已归档的巡检计划不能修改")
+		return
+	}
+
+	// 计划状态只能通过暂停、恢复、归档接口变更
+	status := plan.Status
 	if err := c.ShouldBindJSON(&plan); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	plan.Status = status
 
 	// 验证触发类型和触发日
-	if err := validateTrigger(plan.TriggerType, plan.TriggerDay); err != nil {
+	if err := validateTrigger(plan); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validatePlanWindow(plan); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !validatePlanAssignee(c, plan) {
+		return
+	}
+
+	// 延长有效期或提高次数上限后，已到期的计划重新生效
+	if plan.Status == models.PlanStatusExpired && !services.PlanExpired(plan, time.Now()) {
+		plan.Status = models.PlanStatusActive
+	}
 
 	if err := config.DB.Save(&plan).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检计划失败")
@@ -119,6 +159,10 @@ func ListInspectionPlans(c *gin.Context) {
 	var plans []models.InspectionPlan
 	query := config.DB.Preload("Project").Preload("Route").Preload("Assigner").Preload("Assignees")
 
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
 	if projectID != "" {
 		// 检查用户是否有权限访问该项目
 		if !utils.HasProjectAccess(c, utils.StringToUint(projectID)) {
@@ -154,29 +198,220 @@ func TriggerInspectionPlan(c *gin.Context) {
 		return
 	}
 
-	// 在这里添加触发巡检计划的逻辑
-	// 例如：创建一个新的巡检任务，更新最后触发时间等
+	// 防止在配置的时间窗口内重复触发
+	order, err := services.TriggerPlanManually(plan.ID, utils.CurrentUserID(c))
+	switch {
+	case errors.Is(err, services.ErrPlanNotActive):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, services.ErrPlanTriggeredRecently):
+		utils.ErrorResponse(c, http.StatusConflict, fmt.Sprintf("巡检计划在%s内已触发，请勿重复触发", viper.GetDuration("inspection.manual_trigger_window")))
+		return
+	case err != nil:
+		log.Printf("手动触发巡检计划 %d 生成工单失败: %v", plan.ID, err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成巡检工单失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检计划已手动触发", order)
+}
+
+// PauseInspectionPlan 暂停巡检计划
+func PauseInspectionPlan(c *gin.Context) {
+	changePlanStatus(c, models.PlanStatusPaused, "巡检计划已暂停", models.PlanStatusActive)
+}
+
+// ResumeInspectionPlan 恢复已暂停的巡检计划
+func ResumeInspectionPlan(c *gin.Context) {
+	changePlanStatus(c, models.PlanStatusActive, "巡检计划已恢复", models.PlanStatusPaused)
+}
+
+// ArchiveInspectionPlan 归档巡检计划
+func ArchiveInspectionPlan(c *gin.Context) {
+	changePlanStatus(c, models.PlanStatusArchived, "巡检计划已归档", models.PlanStatusActive, models.PlanStatusPaused, models.PlanStatusExpired)
+}
+
+// changePlanStatus 将处于 from 状态之一的巡检计划变更为 to 状态
+func changePlanStatus(c *gin.Context, to models.PlanStatus, msg string, from ...models.PlanStatus) {
+	id := c.Param("id")
+	var plan models.InspectionPlan
+	if err := config.DB.First(&plan, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检计划不存在")
+		return
+	}
+
+	// 检查用户是否有权限更新该巡检计划
+	if !utils.HasProjectAccess(c, plan.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权更新该巡检计划")
+		return
+	}
+
+	if !slices.Contains(from, plan.Status) {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("巡检计划当前状态为%s，不能执行该操作", plan.Status))
+		return
+	}
+
+	updates := map[string]interface{}{"status": to}
+	// 恢复时从当前时刻起调度，暂停期间错过的触发不再补偿
+	if plan.Status == models.PlanStatusPaused && to == models.PlanStatusActive {
+		updates["last_occurrence"] = time.Now()
+	}
+	if err := config.DB.Model(&plan).Updates(updates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检计划状态失败")
+		return
+	}
+
+	utils.SuccessResponse(c, msg, plan)
+}
+
+// maxPreviewOccurrences 触发时刻预览最多返回的条数
+const maxPreviewOccurrences = 500
+
+// planOccurrence 计划的一次触发时刻
+type planOccurrence struct {
+	Time        time.Time  `json:"time"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"` // 按停检日规则调整后的执行时刻
+	Skipped     bool       `json:"skipped"`                // 落在停检日被跳过
+	Past        bool       `json:"past"`
+	OrderID     *uint      `json:"order_id,omitempty"` // 已生成的工单
+	Missed      bool       `json:"missed"`             // 已过去但未生成工单
+}
+
+// ListPlanOccurrences 预览巡检计划在指定时间范围内的触发时刻
+func ListPlanOccurrences(c *gin.Context) {
+	id := c.Param("id")
+	var plan models.InspectionPlan
+	if err := config.DB.First(&plan, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检计划不存在")
+		return
+	}
+
+	// 检查用户是否有权限访问该巡检计划
+	if !utils.HasProjectAccess(c, plan.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该巡检计划")
+		return
+	}
+
+	loc, err := services.PlanLocation(plan)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	now := time.Now()
-	plan.LastTriggeredAt = &now
-	if err := config.DB.Save(&plan).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检计划失败")
+	from, err := parseTimeQuery(c.Query("from"), loc, now)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的开始时间")
 		return
 	}
+	to, err := parseTimeQuery(c.Query("to"), loc, from.AddDate(0, 0, 30))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的结束时间")
+		return
+	}
+	if to.Before(from) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "结束时间不能早于开始时间")
+		return
+	}
+
+	times, err := services.PlanOccurrences(plan, from.Add(-time.Second), to, maxPreviewOccurrences)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cal, err := services.ProjectCalendar(plan.ProjectID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取项目日历失败")
+		return
+	}
+
+	// 查询已过去的触发时刻对应的工单
+	orderIDs := make(map[int64]uint)
+	if len(times) > 0 && times[0].Before(now) {
+		var orders []models.InspectionOrder
+		if err := config.DB.Where("plan_id = ? AND occurrence BETWEEN ? AND ?", plan.ID, times[0], times[len(times)-1]).Find(&orders).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检工单失败")
+			return
+		}
+		for _, order := range orders {
+			orderIDs[order.Occurrence.Unix()] = order.ID
+		}
+	}
 
-	utils.SuccessResponse(c, "巡检计划已手动触发", plan)
+	occurrences := make([]planOccurrence, 0, len(times))
+	for _, t := range times {
+		occurrence := planOccurrence{Time: t.In(loc)}
+		scheduledAt, ok := services.ScheduledTime(plan, t, cal)
+		if ok {
+			occurrence.ScheduledAt = &scheduledAt
+			occurrence.Past = !scheduledAt.After(now)
+		} else {
+			occurrence.Skipped = true
+			occurrence.Past = !t.After(now)
+		}
+		if orderID, ok := orderIDs[t.Unix()]; ok {
+			occurrence.OrderID = &orderID
+		} else if occurrence.Past && !occurrence.Skipped && t.After(plan.CreatedAt) {
+			occurrence.Missed = true
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+
+	utils.SuccessResponse(c, "获取巡检计划触发时刻成功", gin.H{
+		"time_zone":   loc.String(),
+		"occurrences": occurrences,
+		"truncated":   len(times) >= maxPreviewOccurrences,
+	})
+}
+
+// parseTimeQuery 解析 RFC3339 时间或按计划时区解析 2006-01-02 格式的日期，为空时返回默认值
+func parseTimeQuery(value string, loc *time.Location, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, loc)
 }
 
-func validateTrigger(triggerType models.TriggerType, triggerDay int) error {
-	switch triggerType {
+func validateTrigger(plan models.InspectionPlan) error {
+	switch plan.CatchUpPolicy {
+	case "", models.CatchUpPolicySkip, models.CatchUpPolicyOnce, models.CatchUpPolicyAll:
+	default:
+		return fmt.Errorf("无效的补偿策略")
+	}
+
+	switch plan.AssignmentStrategy {
+	case "", models.AssignmentStrategyFixed, models.AssignmentStrategyRoundRobin, models.AssignmentStrategyLeastLoaded, models.AssignmentStrategyUnassigned:
+	default:
+		return fmt.Errorf("无效的分配策略")
+	}
+
+	switch plan.BlackoutRule {
+	case "", models.BlackoutRuleSkip, models.BlackoutRuleNextWorkday, models.BlackoutRulePreviousWorkday:
+	default:
+		return fmt.Errorf("无效的停检日规则")
+	}
+
+	switch plan.TriggerType {
 	case models.TriggerTypeMonthly:
-		if triggerDay < 1 || triggerDay > 31 {
+		if plan.TriggerDay < 1 || plan.TriggerDay > 31 {
 			return fmt.Errorf("月触发日必须在1到31之间")
 		}
 	case models.TriggerTypeWeekly:
-		if triggerDay < 0 || triggerDay > 6 {
+		if plan.TriggerDay < 0 || plan.TriggerDay > 6 {
 			return fmt.Errorf("周触发日必须在0到6之间（0表示周日）")
 		}
+	case models.TriggerTypeCron:
+		if _, err := services.ParseCronExpr(plan.CronExpr); err != nil {
+			return err
+		}
+	case models.TriggerTypeInterval:
+		if plan.IntervalHours < 1 {
+			return fmt.Errorf("间隔小时数必须大于0")
+		}
 	case models.TriggerTypeManual:
 		// 手动触发不需要验证triggerDay
 	default:
@@ -184,3 +419,33 @@ func validateTrigger(triggerType models.TriggerType, triggerDay int) error {
 	}
 	return nil
 }
+
+// validatePlanAssignee 校验固定执行人，不合法时写入响应并返回 false
+func validatePlanAssignee(c *gin.Context, plan models.InspectionPlan) bool {
+	err := services.ValidatePlanAssignee(plan)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrAssigneeNotFound), errors.Is(err, services.ErrAssigneeNoProjectAccess):
+		utils.ErrorResponse(c, http.StatusBadRequest, "固定执行人"+err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "校验固定执行人失败")
+	}
+	return false
+}
+
+func validatePlanWindow(plan models.InspectionPlan) error {
+	if _, err := services.PlanLocation(plan); err != nil {
+		return err
+	}
+	if plan.StartDate != nil && plan.EndDate != nil && !plan.EndDate.After(*plan.StartDate) {
+		return fmt.Errorf("结束日期必须晚于开始日期")
+	}
+	if plan.MaxOccurrences < 0 {
+		return fmt.Errorf("最大触发次数不能为负数")
+	}
+	if plan.CompletionHours < 0 {
+		return fmt.Errorf("完成时限不能为负数")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
 	"net/http"
 
@@ -17,6 +18,19 @@ func CreateInspectionPoint(c *gin.Context) {
 		return
 	}
 
+	if err := services.ValidatePointLocation(point); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 标签码由系统生成
+	code, err := services.NewTagCode()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成标签码失败")
+		return
+	}
+	point.TagCode = &code
+
 	if err := config.DB.Create(&point).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "创建巡检点失败")
 		return
@@ -45,10 +59,18 @@ func UpdateInspectionPoint(c *gin.Context) {
 		return
 	}
 
+	tagCode := point.TagCode
 	if err := c.ShouldBindJSON(&point); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	// 标签码只能通过重新生成修改
+	point.TagCode = tagCode
+
+	if err := services.ValidatePointLocation(point); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if err := config.DB.Save(&point).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检点失败")
@@ -1,12 +1,16 @@
 package controllers
 
 import (
+	"errors"
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
 	"go-inspect/utils"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
 )
 
 // CreateInspectionRoute 创建巡检路线
@@ -28,6 +32,22 @@ func CreateInspectionRoute(c *gin.Context) {
 		return
 	}
 
+	// 按提交的顺序记录点位的位置
+	pointIDs := make([]uint, 0, len(route.Points))
+	for _, point := range route.Points {
+		pointIDs = append(pointIDs, point.ID)
+	}
+	if err := services.ReorderRoutePoints(route.ID, pointIDs); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "保存巡检点顺序失败")
+		return
+	}
+	routes := []models.InspectionRoute{route}
+	if err := services.LoadRouteStops(config.DB, routes); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检路线点位失败")
+		return
+	}
+	route = routes[0]
+
 	utils.SuccessResponse(c, "巡检路线创建成功", route)
 }
 
@@ -35,7 +55,7 @@ func CreateInspectionRoute(c *gin.Context) {
 func GetInspectionRoute(c *gin.Context) {
 	id := c.Param("id")
 	var route models.InspectionRoute
-	if err := config.DB.Preload("Points").First(&route, id).Error; err != nil {
+	if err := config.DB.First(&route, id).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "巡检路线不存在")
 		return
 	}
@@ -46,6 +66,13 @@ func GetInspectionRoute(c *gin.Context) {
 		return
 	}
 
+	routes := []models.InspectionRoute{route}
+	if err := services.LoadRouteStops(config.DB, routes); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检路线点位失败")
+		return
+	}
+	route = routes[0]
+
 	utils.SuccessResponse(c, "获取巡检路线成功", route)
 }
 
@@ -69,7 +96,8 @@ func UpdateInspectionRoute(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Save(&route).Error; err != nil {
+	// 路线中的点位通过专门的接口调整
+	if err := config.DB.Omit(clause.Associations).Save(&route).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "更新巡检路线失败")
 		return
 	}
@@ -103,7 +131,7 @@ func DeleteInspectionRoute(c *gin.Context) {
 func ListInspectionRoutes(c *gin.Context) {
 	projectID := c.Query("project_id")
 	var routes []models.InspectionRoute
-	query := config.DB.Preload("Points")
+	query := config.DB.Model(&models.InspectionRoute{})
 
 	if projectID != "" {
 		// 检查用户是否有权限访问该项目
@@ -122,63 +150,216 @@ func ListInspectionRoutes(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检路线列表失败")
 		return
 	}
+	if err := services.LoadRouteStops(config.DB, routes); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检路线列表失败")
+		return
+	}
 	utils.SuccessResponse(c, "获取巡检路线列表成功", routes)
 }
 
-// AddPointToRoute 向路线添加巡检点
+// AddPointToRoute 向路线添加巡检点，position 为插入位置（从 1 开始），不填时追加到最后
 func AddPointToRoute(c *gin.Context) {
-	routeID := c.Param("id")
-	var route models.InspectionRoute
-	var point models.InspectionPoint
-
-	if err := config.DB.First(&route, routeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "巡检路线不存在"})
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
 		return
 	}
 
 	var input struct {
-		PointID uint `json:"point_id" binding:"required"`
+		PointID         uint `json:"point_id" binding:"required"`
+		Position        int  `json:"position" binding:"min=0"`
+		ExpectedMinutes int  `json:"expected_minutes" binding:"min=0"`
 	}
-
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	var point models.InspectionPoint
 	if err := config.DB.First(&point, input.PointID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "巡检点不存在"})
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点不存在")
 		return
 	}
 
-	if err := config.DB.Model(&route).Association("Points").Append(&point); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加巡检点失败"})
+	if err := services.InsertRoutePoint(route.ID, point.ID, input.Position, input.ExpectedMinutes); err != nil {
+		respondRouteError(c, err, "添加巡检点失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "巡检点已添加到路线"})
+	utils.SuccessResponse(c, "巡检点已添加到路线", nil)
 }
 
 // RemovePointFromRoute 从路线移除巡检点
 func RemovePointFromRoute(c *gin.Context) {
-	routeID := c.Param("id")
-	pointID := c.Param("pointId")
-	var route models.InspectionRoute
-	var point models.InspectionPoint
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
 
-	if err := config.DB.First(&route, routeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "巡检路线不存在"})
+	if err := services.RemoveRoutePoint(route.ID, utils.StringToUint(c.Param("pointId"))); err != nil {
+		respondRouteError(c, err, "移除巡检点失败")
 		return
 	}
 
-	if err := config.DB.First(&point, pointID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "巡检点不存在"})
+	utils.SuccessResponse(c, "巡检点已从路线移除", nil)
+}
+
+// ReorderRoutePoints 按 point_ids 的顺序重新排列路线中的全部巡检点
+func ReorderRoutePoints(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
 		return
 	}
 
-	if err := config.DB.Model(&route).Association("Points").Delete(&point); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "移除巡检点失败"})
+	var input struct {
+		PointIDs []uint `json:"point_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "巡检点已从路线移除"})
+	if err := services.ReorderRoutePoints(route.ID, input.PointIDs); err != nil {
+		respondRouteError(c, err, "调整巡检点顺序失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检点顺序已调整", nil)
+}
+
+// UpdateRoutePoint 修改路线中巡检点的预计停留时间，或将其移动到 position 指定的位置
+func UpdateRoutePoint(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Position        int  `json:"position" binding:"min=0"`
+		ExpectedMinutes *int `json:"expected_minutes" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := services.UpdateRouteStop(route.ID, utils.StringToUint(c.Param("pointId")), input.Position, input.ExpectedMinutes); err != nil {
+		respondRouteError(c, err, "更新路线点位失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "路线点位已更新", nil)
+}
+
+// OptimizeInspectionRoute 根据点位坐标计算最短巡检顺序，可指定固定的起点和终点，apply 为 true 时直接保存
+func OptimizeInspectionRoute(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartPointID uint `json:"start_point_id"` // 不填时以当前第一个点位为起点
+		EndPointID   uint `json:"end_point_id"`   // 不填时终点不固定
+		Apply        bool `json:"apply"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := services.OptimizeRoute(route.ID, input.StartPointID, input.EndPointID, input.Apply)
+	if err != nil {
+		respondRouteError(c, err, "优化巡检路线失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "巡检路线优化完成", result)
+}
+
+// PublishInspectionRoute 为路线当前的点位和巡检项清单生成版本，内容未变化时返回最新版本
+func PublishInspectionRoute(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
+
+	version, err := services.PublishRouteVersion(route.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成路线版本失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "路线版本已生成", version)
+}
+
+// ListInspectionRouteVersions 获取路线的全部版本
+func ListInspectionRouteVersions(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
+
+	versions, err := services.RouteVersions(route.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取路线版本失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "获取路线版本成功", versions)
+}
+
+// GetInspectionRouteVersion 获取路线的指定版本，包含各点位及其巡检项的快照
+func GetInspectionRouteVersion(c *gin.Context) {
+	route, ok := loadAccessibleRoute(c)
+	if !ok {
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的版本号")
+		return
+	}
+	version, err := services.GetRouteVersion(route.ID, number)
+	if err != nil {
+		if errors.Is(err, services.ErrRouteVersionNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取路线版本失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "获取路线版本成功", version)
+}
+
+// loadAccessibleRoute 读取路径中的巡检路线并检查当前用户是否有权访问
+func loadAccessibleRoute(c *gin.Context) (models.InspectionRoute, bool) {
+	var route models.InspectionRoute
+	if err := config.DB.First(&route, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检路线不存在")
+		return route, false
+	}
+	if !utils.HasProjectAccess(c, route.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权修改该巡检路线")
+		return route, false
+	}
+	return route, true
+}
+
+// respondRouteError 将调整路线点位的错误转换为统一的响应
+func respondRouteError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, services.ErrPointAlreadyInRoute):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPointNotInRoute):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidRouteOrder),
+		errors.Is(err, services.ErrPointsMissingCoordinates),
+		errors.Is(err, services.ErrRouteEndpointInvalid),
+		errors.Is(err, services.ErrRouteTooLarge):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, msg)
+	}
 }
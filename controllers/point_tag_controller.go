@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/services"
+	"go-inspect/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInspectionPointTag 获取巡检点标签的二维码图片用于打印，format 为 png（默认）或 svg，仅管理员可用
+func GetInspectionPointTag(c *gin.Context) {
+	if !requireTagAdmin(c) {
+		return
+	}
+	var point models.InspectionPoint
+	if err := config.DB.First(&point, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点不存在")
+		return
+	}
+	if point.TagCode == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点尚未生成标签码")
+		return
+	}
+
+	renderTagSheet(c, []services.TagLabel{{Code: *point.TagCode, Name: point.Name}})
+}
+
+// RegenerateInspectionPointTag 重新生成巡检点的标签码，原标签随即失效，需重新打印张贴，仅管理员可用
+func RegenerateInspectionPointTag(c *gin.Context) {
+	if !requireTagAdmin(c) {
+		return
+	}
+	var point models.InspectionPoint
+	if err := config.DB.First(&point, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点不存在")
+		return
+	}
+
+	code, err := services.NewTagCode()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成标签码失败")
+		return
+	}
+	if err := config.DB.Model(&point).Update("tag_code", code).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新标签码失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "标签码已重新生成", point)
+}
+
+// GetInspectionPointTagPayload 获取巡检点的限时签名标签内容，供现场电子屏或可写 NFC 标签定时刷新，
+// 仅现场设备账号和管理员可用，执行人不能绕过扫描直接获取
+func GetInspectionPointTagPayload(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, utils.CurrentUserID(c)).Error; err != nil || (user.ProjectID != nil && user.Role != services.TagDeviceRole()) {
+		utils.ErrorResponse(c, http.StatusForbidden, "仅现场设备或管理员可以获取标签内容")
+		return
+	}
+
+	var point models.InspectionPoint
+	if err := config.DB.First(&point, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点不存在")
+		return
+	}
+	if point.TagCode == nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检点尚未生成标签码")
+		return
+	}
+
+	expires := time.Now().Add(services.TagPayloadTTL())
+	utils.SuccessResponse(c, "获取标签内容成功", gin.H{
+		"payload":    services.SignTagPayload(*point.TagCode, expires),
+		"expires_at": expires,
+	})
+}
+
+// GetRouteTagSheet 按路线中的点位顺序生成标签打印单，format 为 png（默认）或 svg，仅管理员可用
+func GetRouteTagSheet(c *gin.Context) {
+	if !requireTagAdmin(c) {
+		return
+	}
+	var route models.InspectionRoute
+	if err := config.DB.First(&route, c.Param("id")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "巡检路线不存在")
+		return
+	}
+	if !utils.HasProjectAccess(c, route.ProjectID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "无权访问该巡检路线")
+		return
+	}
+	points, err := services.RoutePoints(config.DB, route.ID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取巡检路线点位失败")
+		return
+	}
+
+	labels := make([]services.TagLabel, 0, len(points))
+	for _, point := range points {
+		if point.TagCode != nil {
+			labels = append(labels, services.TagLabel{Code: *point.TagCode, Name: point.Name})
+		}
+	}
+	if len(labels) == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "路线中没有可打印的巡检点标签")
+		return
+	}
+
+	renderTagSheet(c, labels)
+}
+
+// requireTagAdmin 标签码用于证明执行人到过现场，只有管理员可以获取标签内容
+func requireTagAdmin(c *gin.Context) bool {
+	if !utils.IsAdmin(c) {
+		utils.ErrorResponse(c, http.StatusForbidden, "仅管理员可以获取巡检点标签")
+		return false
+	}
+	return true
+}
+
+// renderTagSheet 按 format 参数输出标签打印单
+func renderTagSheet(c *gin.Context, labels []services.TagLabel) {
+	render, contentType := services.RenderTagSheetPNG, "image/png"
+	switch c.DefaultQuery("format", "png") {
+	case "png":
+	case "svg":
+		render, contentType = services.RenderTagSheetSVG, "image/svg+xml"
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "format 只能为 png 或 svg")
+		return
+	}
+
+	data, err := render(labels)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成标签图片失败")
+		return
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/services"
+	"go-inspect/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncOperations 按顺序执行离线期间排队的开始、确认和完成操作，逐个返回处理结果
+func SyncOperations(c *gin.Context) {
+	var input struct {
+		Operations []services.SyncOperationInput `json:"operations" binding:"required,max=500,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, utils.CurrentUserID(c)).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户不存在")
+		return
+	}
+
+	actor := services.SyncActor{
+		UserID:     user.ID,
+		UserRole:   user.Role,
+		ProjectIDs: utils.GetAccessibleProjectIDs(c),
+	}
+	results := services.ApplySyncBatch(actor, input.Operations)
+
+	utils.SuccessResponse(c, "同步完成", results)
+}
+
+// DownloadOfflinePackage 下载当前用户未关闭的工单及其路线、巡检点和巡检项，供离线巡检使用
+func DownloadOfflinePackage(c *gin.Context) {
+	pkg, err := services.BuildOfflinePackage(utils.CurrentUserID(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "生成离线巡检数据失败")
+		return
+	}
+
+	utils.SuccessResponse(c, "获取离线巡检数据成功", pkg)
+}
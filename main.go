@@ -5,6 +5,8 @@ import (
 	"go-inspect/config"
 	"go-inspect/models"
 	"go-inspect/routes"
+	"go-inspect/services"
+	"go-inspect/storage"
 	"go-inspect/tasks"
 	"log"
 
@@ -38,8 +40,23 @@ func main() {
 	// 初始化全局 DB 变量
 	config.InitDB(db)
 
+	// 路线与巡检点的连接表记录顺序和预计停留时间
+	if err := config.DB.SetupJoinTable(&models.InspectionRoute{}, "Points", &models.RoutePoint{}); err != nil {
+		log.Fatalf("Failed to setup join table: %s", err)
+	}
+
 	// 自动迁移模型
-	config.DB.AutoMigrate(&models.User{}, &models.InspectionPoint{}, &models.InspectionRoute{}, &models.InspectionPlan{}, &models.InspectionOrder{}, &models.InspectionPointCheck{})
+	config.DB.AutoMigrate(&models.User{}, &models.InspectionPoint{}, &models.InspectionRoute{}, &models.InspectionPlan{}, &models.InspectionOrder{}, &models.InspectionPointCheck{}, &models.BlackoutDate{}, &models.OrderEvent{}, &models.InspectionItemResult{}, &models.Attachment{}, &models.Defect{}, &models.SyncOperation{}, &models.RouteVersion{})
+
+	// 为已有的巡检点补充标签码
+	if err := services.BackfillTagCodes(); err != nil {
+		log.Fatalf("Failed to backfill tag codes: %s", err)
+	}
+
+	// 初始化附件存储
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to init storage: %s", err)
+	}
 
 	// 设置 Gin 模式
 	gin.SetMode(viper.GetString("server.mode"))
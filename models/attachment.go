@@ -0,0 +1,19 @@
+package models
+
+import "gorm.io/gorm"
+
+// Attachment 巡检工单、巡检点确认或巡检项上的附件
+type Attachment struct {
+	gorm.Model
+	OrderID      uint   `gorm:"not null;index" json:"order_id"`
+	CheckID      *uint  `gorm:"index" json:"check_id"` // 为空表示工单附件
+	ItemID       *uint  `json:"item_id"`               // 不为空表示巡检项附件
+	FileName     string `gorm:"type:varchar(255)" json:"file_name"`
+	ContentType  string `gorm:"type:varchar(100)" json:"content_type"`
+	Size         int64  `json:"size"`
+	StorageKey   string `gorm:"type:varchar(255);not null" json:"-"`
+	ThumbnailKey string `gorm:"type:varchar(255)" json:"-"` // 图片附件的缩略图
+	UploadedByID uint   `json:"uploaded_by_id"`
+	URL          string `gorm:"-" json:"url,omitempty"`           // 限时下载地址
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"` // 限时缩略图地址
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// BlackoutDate 项目日历中的停检日，如法定节假日、停产检修
+type BlackoutDate struct {
+	gorm.Model
+	ProjectID       uint    `gorm:"not null;uniqueIndex:idx_blackout_project_date" json:"project_id"`
+	Project         Project `gorm:"foreignKey:ProjectID" json:"-"`
+	Date            string  `gorm:"type:char(10);not null;uniqueIndex:idx_blackout_project_date" json:"date"` // 2006-01-02，按计划时区解释，同一项目不重复
+	Name            string  `gorm:"type:varchar(100)" json:"name"`
+	ApplyToChildren bool    `gorm:"default:true" json:"apply_to_children"` // 是否同时作用于子项目
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DefectStatus string
+
+const (
+	DefectStatusOpen                DefectStatus = "open"                 // 待整改
+	DefectStatusInRepair            DefectStatus = "in_repair"            // 整改中
+	DefectStatusPendingVerification DefectStatus = "pending_verification" // 已整改，待验证
+	DefectStatusClosed              DefectStatus = "closed"
+)
+
+type DefectSeverity string
+
+const (
+	DefectSeverityLow      DefectSeverity = "low"
+	DefectSeverityMedium   DefectSeverity = "medium"
+	DefectSeverityHigh     DefectSeverity = "high"
+	DefectSeverityCritical DefectSeverity = "critical"
+)
+
+// Defect 巡检不合格时生成的整改单
+type Defect struct {
+	gorm.Model
+	ProjectID           uint             `gorm:"not null;index" json:"project_id"`
+	OrderID             uint             `gorm:"not null;index" json:"order_id"` // 发现问题的巡检工单
+	Order               *InspectionOrder `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	CheckID             uint             `gorm:"not null;index" json:"check_id"`
+	PointID             uint             `gorm:"not null;index" json:"point_id"`
+	Point               *InspectionPoint `gorm:"foreignKey:PointID" json:"point,omitempty"`
+	ItemID              *uint            `json:"item_id"` // 不合格的巡检项，为空表示整个点位不合格
+	Item                *InspectionItem  `gorm:"foreignKey:ItemID" json:"item,omitempty"`
+	Title               string           `gorm:"type:varchar(255);not null" json:"title"`
+	Description         string           `gorm:"type:text" json:"description"`
+	Severity            DefectSeverity   `gorm:"type:varchar(20);not null;default:medium" json:"severity"`
+	Status              DefectStatus     `gorm:"type:varchar(30);not null;default:open;index" json:"status"`
+	ReportedByID        *uint            `json:"reported_by_id"`
+	ResponsibleID       *uint            `json:"responsible_id"` // 整改负责人
+	Responsible         *User            `gorm:"foreignKey:ResponsibleID" json:"responsible,omitempty"`
+	DueAt               *time.Time       `json:"due_at"` // 整改期限
+	RepairNote          string           `gorm:"type:text" json:"repair_note"`
+	RepairedAt          *time.Time       `json:"repaired_at"`
+	VerificationOrderID *uint            `json:"verification_order_id"` // 提交整改后生成的复检工单，复检合格后自动关闭
+	ClosedByID          *uint            `json:"closed_by_id"`
+	ClosedAt            *time.Time       `json:"closed_at"`
+	StatusReason        string           `gorm:"type:varchar(500)" json:"status_reason"` // 最近一次退回或关闭的原因
+}
@@ -2,10 +2,27 @@ package models
 
 import "gorm.io/gorm"
 
+// ItemType 巡检项的填写类型
+type ItemType string
+
+const (
+	ItemTypeBoolean      ItemType = "boolean"       // 合格/不合格
+	ItemTypeNumeric      ItemType = "numeric"       // 数值读数，超出上下限即不合格
+	ItemTypeSingleChoice ItemType = "single_choice" // 单选
+	ItemTypeMultiChoice  ItemType = "multi_choice"  // 多选
+	ItemTypeText         ItemType = "text"          // 文字描述
+	ItemTypePhoto        ItemType = "photo"         // 必须拍照
+)
+
 type InspectionItem struct {
 	gorm.Model
 	Title           string            `gorm:"type:varchar(255);not null" json:"title"`
 	Details         string            `gorm:"type:text" json:"details"`
 	ExecutionMethod string            `gorm:"type:varchar(100);not null" json:"execution_method"`
+	Type            ItemType          `gorm:"type:varchar(20);default:boolean" json:"type"`
+	Unit            string            `gorm:"type:varchar(20)" json:"unit"`             // 数值读数的单位
+	MinValue        *float64          `json:"min_value"`                                // 数值读数下限，为空表示不限
+	MaxValue        *float64          `json:"max_value"`                                // 数值读数上限，为空表示不限
+	Options         []string          `gorm:"type:text;serializer:json" json:"options"` // 单选、多选的选项
 	Points          []InspectionPoint `gorm:"many2many:point_items;" json:"points"`
 }
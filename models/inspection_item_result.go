@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+// InspectionItemResult 巡检点确认中单个巡检项的结果
+type InspectionItemResult struct {
+	gorm.Model
+	OrderID    uint           `gorm:"not null;index" json:"order_id"`
+	CheckID    uint           `gorm:"not null;uniqueIndex:idx_item_result_check_item" json:"check_id"`
+	PointID    uint           `gorm:"not null" json:"point_id"`
+	ItemID     uint           `gorm:"not null;uniqueIndex:idx_item_result_check_item" json:"item_id"`
+	Item       InspectionItem `gorm:"foreignKey:ItemID" json:"item"`
+	Status     CheckStatus    `gorm:"type:varchar(20);not null" json:"status"`
+	Value      string         `gorm:"type:text" json:"value"`            // 多选时为选项的 JSON 数组
+	Reading    *float64       `json:"reading"`                           // 数值读数
+	OutOfRange bool           `gorm:"default:false" json:"out_of_range"` // 数值读数超出上下限
+	Comment    string         `gorm:"type:text" json:"comment"`
+}
@@ -13,20 +13,44 @@ const (
 	OrderStatusAssigned   OrderStatus = "assigned"
 	OrderStatusInProgress OrderStatus = "in_progress"
 	OrderStatusCompleted  OrderStatus = "completed"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusRejected   OrderStatus = "rejected"     // 执行人拒绝，等待重新分配或认领
+	OrderStatusOverdue    OrderStatus = "overdue"      // 超过截止时间仍未开始
+	OrderStatusReopened   OrderStatus = "reopened"     // 退回返工
+	OrderStatusReview     OrderStatus = "under_review" // 已提交，等待审核
 )
 
 type InspectionOrder struct {
 	gorm.Model
-	PlanID          uint           `gorm:"not null" json:"plan_id"`
-	Plan            InspectionPlan `gorm:"foreignKey:PlanID" json:"plan"`
-	Status          OrderStatus    `gorm:"type:varchar(20);not null" json:"status"`
-	AssignerID      *uint          `json:"assigner_id"`
-	Assigner        *User          `gorm:"foreignKey:AssignerID" json:"assigner"`
-	AssigneeID      *uint          `json:"assignee_id"`
-	Assignee        *User          `gorm:"foreignKey:AssigneeID" json:"assignee"`
-	StartTime       *time.Time     `json:"start_time"`
-	EndTime         *time.Time     `json:"end_time"`
-	InspectionData  string         `gorm:"type:text" json:"inspection_data"`
-	CompletedChecks int            `gorm:"default:0" json:"completed_checks"`
-	TotalChecks     int            `gorm:"default:0" json:"total_checks"`
+	PlanID          uint                   `gorm:"not null;uniqueIndex:idx_order_plan_occurrence" json:"plan_id"`
+	Plan            InspectionPlan         `gorm:"foreignKey:PlanID" json:"plan"`
+	Occurrence      *time.Time             `gorm:"uniqueIndex:idx_order_plan_occurrence" json:"occurrence"` // 对应的计划触发时刻，手动触发和复检工单为空
+	Manual          bool                   `gorm:"default:false" json:"manual"`                             // 手动触发生成的工单
+	ScheduledAt     *time.Time             `json:"scheduled_at"`                                            // 按停检日规则调整后的实际执行时刻
+	Status          OrderStatus            `gorm:"type:varchar(20);not null" json:"status"`
+	AssignerID      *uint                  `json:"assigner_id"`
+	Assigner        *User                  `gorm:"foreignKey:AssignerID" json:"assigner"`
+	AssigneeID      *uint                  `json:"assignee_id"`
+	Assignee        *User                  `gorm:"foreignKey:AssigneeID" json:"assignee"`
+	StartTime       *time.Time             `json:"start_time"`
+	EndTime         *time.Time             `json:"end_time"`
+	InspectionData  string                 `gorm:"type:text" json:"inspection_data"`
+	ReviewedByID    *uint                  `json:"reviewed_by_id"`
+	ReviewedBy      *User                  `gorm:"foreignKey:ReviewedByID" json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time             `json:"reviewed_at"`
+	ReviewComment   string                 `gorm:"type:text" json:"review_comment"`
+	StatusReason    string                 `gorm:"type:varchar(500)" json:"status_reason"` // 最近一次拒绝、取消或退回的原因
+	DueAt           *time.Time             `json:"due_at"`
+	OverdueAt       *time.Time             `json:"overdue_at"`                        // 检测到逾期的时间
+	EscalationLevel int                    `gorm:"default:0" json:"escalation_level"` // 已升级到升级链的第几级，0 表示未升级
+	EscalatedToID   *uint                  `json:"escalated_to_id"`
+	EscalatedTo     *User                  `gorm:"foreignKey:EscalatedToID" json:"escalated_to,omitempty"`
+	EscalatedAt     *time.Time             `json:"escalated_at"`
+	CompletedChecks int                    `gorm:"default:0" json:"completed_checks"`
+	TotalChecks     int                    `gorm:"default:0" json:"total_checks"`
+	DefectID        *uint                  `gorm:"index" json:"defect_id"`        // 复检的整改单，非空表示整改后的复检工单
+	RouteVersionID  *uint                  `gorm:"index" json:"route_version_id"` // 生成时固定的路线版本，为空表示升级前的工单，按现有路线和巡检项展示
+	RouteVersion    *RouteVersion          `gorm:"foreignKey:RouteVersionID" json:"route_version,omitempty"`
+	Checks          []InspectionPointCheck `gorm:"foreignKey:OrderID" json:"checks,omitempty"`
+	Version         int                    `gorm:"not null;default:1" json:"version"` // 乐观锁版本号，每次修改工单时递增
 }
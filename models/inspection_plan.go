@@ -9,22 +9,79 @@ import (
 type TriggerType string
 
 const (
-	TriggerTypeMonthly TriggerType = "monthly"
-	TriggerTypeWeekly  TriggerType = "weekly"
-	TriggerTypeManual  TriggerType = "manual"
+	TriggerTypeMonthly  TriggerType = "monthly"
+	TriggerTypeWeekly   TriggerType = "weekly"
+	TriggerTypeManual   TriggerType = "manual"
+	TriggerTypeCron     TriggerType = "cron"     // 按 cron 表达式触发
+	TriggerTypeInterval TriggerType = "interval" // 每隔 N 小时触发
+)
+
+// CatchUpPolicy 调度器停机期间错过的触发如何补偿
+type CatchUpPolicy string
+
+const (
+	CatchUpPolicySkip CatchUpPolicy = "skip" // 跳过错过的触发
+	CatchUpPolicyOnce CatchUpPolicy = "once" // 只补最近一次
+	CatchUpPolicyAll  CatchUpPolicy = "all"  // 逐次全部补齐
+)
+
+// PlanStatus 巡检计划状态
+type PlanStatus string
+
+const (
+	PlanStatusActive   PlanStatus = "active"
+	PlanStatusPaused   PlanStatus = "paused"   // 暂停，恢复后不补偿暂停期间的触发
+	PlanStatusArchived PlanStatus = "archived" // 归档，不再触发也不可恢复，保留历史工单
+	PlanStatusExpired  PlanStatus = "expired"  // 超过结束日期或触发次数上限，已自动停用
+)
+
+// BlackoutRule 触发时刻落在停检日时的处理方式
+type BlackoutRule string
+
+const (
+	BlackoutRuleSkip            BlackoutRule = "skip"             // 跳过本次触发
+	BlackoutRuleNextWorkday     BlackoutRule = "next_workday"     // 顺延到下一个工作日
+	BlackoutRulePreviousWorkday BlackoutRule = "previous_workday" // 提前到上一个工作日
+)
+
+// AssignmentStrategy 生成工单时选择执行人的方式
+type AssignmentStrategy string
+
+const (
+	AssignmentStrategyFixed       AssignmentStrategy = "fixed"        // 固定执行人
+	AssignmentStrategyRoundRobin  AssignmentStrategy = "round_robin"  // 在计划执行人中轮流分配
+	AssignmentStrategyLeastLoaded AssignmentStrategy = "least_loaded" // 分配给未完成工单最少的执行人
+	AssignmentStrategyUnassigned  AssignmentStrategy = "unassigned"   // 不分配，由执行人认领
 )
 
 type InspectionPlan struct {
 	gorm.Model
-	Name            string          `gorm:"type:varchar(100);not null" json:"name"`
-	ProjectID       uint            `gorm:"not null" json:"project_id"`
-	Project         Project         `gorm:"foreignKey:ProjectID" json:"project"`
-	RouteID         uint            `gorm:"not null" json:"route_id"`
-	Route           InspectionRoute `gorm:"foreignKey:RouteID" json:"route"`
-	TriggerType     TriggerType     `gorm:"type:varchar(20);not null" json:"trigger_type"`
-	TriggerDay      int             `gorm:"type:int" json:"trigger_day"` // 1-31 for monthly, 0-6 for weekly (0 = Sunday)
-	AssignerID      uint            `gorm:"not null" json:"assigner_id"`
-	Assigner        User            `gorm:"foreignKey:AssignerID" json:"assigner"`
-	Assignees       []User          `gorm:"many2many:plan_assignees;" json:"assignees"`
-	LastTriggeredAt *time.Time      `json:"last_triggered_at"`
+	Name               string             `gorm:"type:varchar(100);not null" json:"name"`
+	ProjectID          uint               `gorm:"not null" json:"project_id"`
+	Project            Project            `gorm:"foreignKey:ProjectID" json:"project"`
+	RouteID            uint               `gorm:"not null" json:"route_id"`
+	Route              InspectionRoute    `gorm:"foreignKey:RouteID" json:"route"`
+	TriggerType        TriggerType        `gorm:"type:varchar(20);not null" json:"trigger_type"`
+	TriggerDay         int                `gorm:"type:int" json:"trigger_day"`        // 1-31 for monthly (clamped to the last day of shorter months), 0-6 for weekly (0 = Sunday)
+	CronExpr           string             `gorm:"type:varchar(100)" json:"cron_expr"` // 五段式 cron 表达式，日字段支持 L（月末）
+	IntervalHours      int                `gorm:"type:int" json:"interval_hours"`
+	CatchUpPolicy      CatchUpPolicy      `gorm:"type:varchar(20);default:once" json:"catch_up_policy"`
+	BlackoutRule       BlackoutRule       `gorm:"type:varchar(20);default:skip" json:"blackout_rule"`
+	TimeZone           string             `gorm:"type:varchar(64)" json:"time_zone"` // IANA 时区，如 Asia/Shanghai，为空时使用服务器时区
+	StartDate          *time.Time         `json:"start_date"`
+	EndDate            *time.Time         `json:"end_date"`
+	MaxOccurrences     int                `gorm:"default:0" json:"max_occurrences"` // 0 表示不限次数
+	OccurrenceCount    int                `gorm:"default:0" json:"occurrence_count"`
+	Status             PlanStatus         `gorm:"type:varchar(20);default:active" json:"status"`
+	AssignerID         uint               `gorm:"not null" json:"assigner_id"`
+	Assigner           User               `gorm:"foreignKey:AssignerID" json:"assigner"`
+	Assignees          []User             `gorm:"many2many:plan_assignees;" json:"assignees"`
+	AssignmentStrategy AssignmentStrategy `gorm:"type:varchar(20);default:unassigned" json:"assignment_strategy"`
+	FixedAssigneeID    *uint              `json:"fixed_assignee_id"`                 // 固定分配时的执行人，为空时取计划的第一个执行人
+	SkipReview         bool               `gorm:"default:false" json:"skip_review"`  // 完成后无需审核直接结单
+	CompletionHours    int                `gorm:"default:0" json:"completion_hours"` // 工单需在执行时刻后多少小时内完成，0 表示不设期限
+	LastAssigneeID     *uint              `json:"last_assignee_id"`                  // 轮流分配的进度，重启后继续轮转
+	LastTriggeredAt    *time.Time         `json:"last_triggered_at"`                 // 升级前的调度记录，计划没有 LastOccurrence 时作为调度起点
+	LastManualAt       *time.Time         `json:"last_manual_at"`                    // 最近一次手动触发的时间，用于限制重复手动触发
+	LastOccurrence     *time.Time         `json:"last_occurrence"`                   // 调度器最近一次处理的计划触发时刻
 }
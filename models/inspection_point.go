@@ -2,10 +2,25 @@ package models
 
 import "gorm.io/gorm"
 
+// GeofenceMode 巡检点确认位置超出地理围栏时的处理方式
+type GeofenceMode string
+
+const (
+	GeofenceModeFlag   GeofenceMode = "flag"   // 记录超出围栏，允许确认
+	GeofenceModeReject GeofenceMode = "reject" // 拒绝确认
+)
+
 type InspectionPoint struct {
 	gorm.Model
-	Name        string           `gorm:"type:varchar(100);not null" json:"name"`
-	Description string           `gorm:"type:text" json:"description"`
-	Location    string           `gorm:"type:varchar(255)" json:"location"`
-	Items       []InspectionItem `gorm:"many2many:point_items;" json:"items"`
+	Name           string           `gorm:"type:varchar(100);not null" json:"name"`
+	Description    string           `gorm:"type:text" json:"description"`
+	Location       string           `gorm:"type:varchar(255)" json:"location"`
+	Latitude       *float64         `json:"latitude"`
+	Longitude      *float64         `json:"longitude"`
+	Floor          string           `gorm:"type:varchar(20)" json:"floor"`
+	Zone           string           `gorm:"type:varchar(50)" json:"zone"`
+	GeofenceRadius float64          `gorm:"default:0" json:"geofence_radius"`      // 地理围栏半径（米），0 表示不校验位置
+	GeofenceMode   GeofenceMode     `gorm:"type:varchar(10)" json:"geofence_mode"` // 为空时使用 inspection.geofence.mode 配置
+	TagCode        *string          `gorm:"type:varchar(32);uniqueIndex" json:"-"` // 现场张贴的二维码/NFC 标签码，确认巡检点时需扫描，只能通过管理员打印标签获取
+	Items          []InspectionItem `gorm:"many2many:point_items;" json:"items"`
 }
@@ -1,6 +1,10 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type CheckStatus string
 
@@ -12,10 +16,19 @@ const (
 
 type InspectionPointCheck struct {
 	gorm.Model
-	OrderID uint            `gorm:"not null" json:"order_id"`
-	Order   InspectionOrder `gorm:"foreignKey:OrderID" json:"order"`
-	PointID uint            `gorm:"not null" json:"point_id"`
-	Point   InspectionPoint `gorm:"foreignKey:PointID" json:"point"`
-	Status  CheckStatus     `gorm:"type:varchar(20);not null" json:"status"`
-	Comment string          `gorm:"type:text" json:"comment"`
+	OrderID         uint                   `gorm:"not null" json:"order_id"`
+	Order           InspectionOrder        `gorm:"foreignKey:OrderID" json:"order"`
+	PointID         uint                   `gorm:"not null" json:"point_id"`
+	Point           InspectionPoint        `gorm:"foreignKey:PointID" json:"point"`
+	Sequence        int                    `gorm:"default:0" json:"sequence"` // 在路线中的顺序，从 1 开始
+	Status          CheckStatus            `gorm:"type:varchar(20);not null" json:"status"`
+	Comment         string                 `gorm:"type:text" json:"comment"`
+	CheckedAt       *time.Time             `json:"checked_at"` // 确认时间，离线同步时为客户端记录的时间
+	Latitude        *float64               `json:"latitude"`   // 确认时的 GPS 位置
+	Longitude       *float64               `json:"longitude"`
+	Accuracy        *float64               `json:"accuracy"`                                    // GPS 精度（米）
+	Distance        *float64               `json:"distance"`                                    // 与巡检点的距离（米）
+	OutsideGeofence bool                   `gorm:"default:false" json:"outside_geofence"`       // 确认位置超出巡检点的地理围栏
+	ScannedAt       *time.Time             `json:"scanned_at"`                                  // 扫描巡检点标签的时间
+	Results         []InspectionItemResult `gorm:"foreignKey:CheckID" json:"results,omitempty"` // 各巡检项的结果，点位有巡检项时据此得出 Status
 }
@@ -4,9 +4,12 @@ import "gorm.io/gorm"
 
 type InspectionRoute struct {
 	gorm.Model
-	Name        string            `gorm:"type:varchar(100);not null" json:"name"`
-	Description string            `gorm:"type:text" json:"description"`
-	Points      []InspectionPoint `gorm:"many2many:route_points;" json:"points"`
-	ProjectID   uint              `gorm:"not null" json:"project_id"`
-	Project     Project           `gorm:"foreignkey:ProjectID" json:"project"`
+	Name             string            `gorm:"type:varchar(100);not null" json:"name"`
+	Description      string            `gorm:"type:text" json:"description"`
+	EnforceSequence  bool              `gorm:"default:false" json:"enforce_sequence"` // 是否要求按路线顺序依次确认巡检点
+	Points           []InspectionPoint `gorm:"many2many:route_points;" json:"points"` // 按 Stops 中的顺序排列
+	Stops            []RoutePoint      `gorm:"-" json:"stops,omitempty"`              // 各点位的顺序和预计停留时间
+	EstimatedMinutes int               `gorm:"-" json:"estimated_minutes"`            // 各点位预计停留时间之和
+	ProjectID        uint              `gorm:"not null" json:"project_id"`
+	Project          Project           `gorm:"foreignkey:ProjectID" json:"project"`
 }
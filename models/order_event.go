@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OrderEvent 巡检工单的状态迁移和分配变更记录，只追加不修改
+type OrderEvent struct {
+	ID                uint        `gorm:"primarykey" json:"id"`
+	OrderID           uint        `gorm:"not null;index" json:"order_id"`
+	ActorID           *uint       `json:"actor_id"` // 为空表示系统操作
+	Actor             *User       `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+	Action            string      `gorm:"type:varchar(20);not null" json:"action"`
+	FromStatus        OrderStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus          OrderStatus `gorm:"type:varchar(20)" json:"to_status"`
+	FromAssigneeID    *uint       `json:"from_assignee_id"`
+	ToAssigneeID      *uint       `json:"to_assignee_id"`
+	FromEscalation    int         `gorm:"default:0" json:"from_escalation"` // 变更前的升级级别
+	ToEscalation      int         `gorm:"default:0" json:"to_escalation"`
+	FromEscalatedToID *uint       `json:"from_escalated_to_id"` // 变更前的升级对象
+	ToEscalatedToID   *uint       `json:"to_escalated_to_id"`
+	Reason            string      `gorm:"type:varchar(500)" json:"reason"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
@@ -8,6 +8,8 @@ type Project struct {
 	gorm.Model
 	Name        string    `gorm:"type:varchar(255);not null" json:"name"`
 	Description string    `gorm:"type:text" json:"description"`
+	OwnerID     *uint     `json:"owner_id"` // 项目负责人，可作为工单逾期升级的对象
+	Owner       *User     `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
 	ParentID    *uint     `json:"parent_id"`
 	Parent      *Project  `gorm:"foreignkey:ParentID" json:"parent,omitempty"`
 	Children    []Project `gorm:"foreignkey:ParentID" json:"children,omitempty"`
@@ -0,0 +1,9 @@
+package models
+
+// RoutePoint 巡检路线与巡检点的关联（route_points 连接表），记录点位在路线中的顺序和预计停留时间
+type RoutePoint struct {
+	InspectionRouteID uint `gorm:"primaryKey" json:"route_id"`
+	InspectionPointID uint `gorm:"primaryKey" json:"point_id"`
+	Position          int  `gorm:"not null;default:0" json:"position"`         // 从 1 开始的巡检顺序
+	ExpectedMinutes   int  `gorm:"not null;default:0" json:"expected_minutes"` // 预计在该点位花费的时间（分钟）
+}
@@ -0,0 +1,41 @@
+package models
+
+import "gorm.io/gorm"
+
+// ItemSnapshot 巡检项在路线版本中的快照
+type ItemSnapshot struct {
+	ItemID          uint     `json:"item_id"`
+	Title           string   `json:"title"`
+	Details         string   `json:"details"`
+	ExecutionMethod string   `json:"execution_method"`
+	Type            ItemType `json:"type"`
+	Unit            string   `json:"unit"`
+	MinValue        *float64 `json:"min_value"`
+	MaxValue        *float64 `json:"max_value"`
+	Options         []string `json:"options"`
+}
+
+// PointSnapshot 巡检点及其巡检项清单在路线版本中的快照
+type PointSnapshot struct {
+	PointID         uint           `json:"point_id"`
+	Position        int            `json:"position"`
+	ExpectedMinutes int            `json:"expected_minutes"`
+	Name            string         `json:"name"`
+	Description     string         `json:"description"`
+	Location        string         `json:"location"`
+	Floor           string         `json:"floor"`
+	Zone            string         `json:"zone"`
+	Items           []ItemSnapshot `json:"items"`
+}
+
+// RouteVersion 路线及各点位巡检项清单的不可变快照，工单生成时固定到当时的版本
+type RouteVersion struct {
+	gorm.Model
+	RouteID         uint            `gorm:"not null;uniqueIndex:idx_route_version" json:"route_id"`
+	Version         int             `gorm:"not null;uniqueIndex:idx_route_version" json:"version"` // 路线内从 1 开始递增
+	Name            string          `gorm:"type:varchar(100)" json:"name"`
+	Description     string          `gorm:"type:text" json:"description"`
+	EnforceSequence bool            `gorm:"default:false" json:"enforce_sequence"`
+	Checksum        string          `gorm:"type:char(64);not null" json:"checksum"` // 快照内容的 SHA-256，内容未变化时沿用上一版本
+	Points          []PointSnapshot `gorm:"type:longtext;serializer:json" json:"points,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type SyncStatus string
+
+const (
+	SyncStatusPending  SyncStatus = "pending"  // 已占用操作ID，正在执行
+	SyncStatusApplied  SyncStatus = "applied"  // 已执行
+	SyncStatusConflict SyncStatus = "conflict" // 与服务端状态冲突，如工单已改派或已被他人修改
+	SyncStatusRejected SyncStatus = "rejected" // 操作内容不合法
+	SyncStatusFailed   SyncStatus = "failed"   // 服务端处理失败，可以重试，不记录
+)
+
+// SyncOperation 离线同步已处理的操作，按用户和客户端操作ID去重，执行前先插入占用，重复提交时返回首次处理的结果
+type SyncOperation struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_sync_user_client" json:"user_id"`
+	ClientID     string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_sync_user_client" json:"client_id"`
+	Type         string     `gorm:"type:varchar(20);not null" json:"type"`
+	OrderID      uint       `gorm:"index" json:"order_id"`
+	PointID      uint       `json:"point_id"`
+	ClientTime   *time.Time `json:"client_time"`
+	Status       SyncStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Message      string     `gorm:"type:varchar(500)" json:"message"`
+	OrderVersion int        `json:"order_version"` // 处理后工单的版本号
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -2,11 +2,17 @@ package models
 
 import "gorm.io/gorm"
 
+const (
+	RoleInspector  = "inspector"  // 巡检员，注册用户的默认角色
+	RoleSupervisor = "supervisor" // 主管，默认的审核角色
+)
+
 type User struct {
 	gorm.Model
 	Username  string  `gorm:"uniqueIndex;type:varchar(100);not null" json:"username"`
 	Password  string  `json:"-" gorm:"column:password;not null"`
 	Email     string  `gorm:"uniqueIndex;type:varchar(100)" json:"email"`
+	Role      string  `gorm:"type:varchar(20);default:inspector" json:"role"` // 用户角色，如 inspector、supervisor
 	ProjectID *uint   `json:"project_id"`
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 }
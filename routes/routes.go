@@ -14,6 +14,8 @@ func SetupRoutes(r *gin.Engine) {
 	{
 		public.POST("/register", controllers.Register)
 		public.POST("/login", controllers.Login)
+		// 附件下载通过限时签名校验
+		public.GET("/attachments/:id/download", controllers.DownloadAttachment)
 	}
 
 	// 需要认证的路由
@@ -24,6 +26,7 @@ func SetupRoutes(r *gin.Engine) {
 		protected.GET("/user", controllers.GetUserInfo)
 		protected.PUT("/user", controllers.UpdateUserInfo)
 		protected.POST("/user/changePassword", controllers.ChangePassword)
+		protected.PUT("/users/:id/role", controllers.UpdateUserRole)
 
 		// 巡检点位管理路由
 		inspectionPoints := protected.Group("/inspectionPoints")
@@ -33,6 +36,9 @@ func SetupRoutes(r *gin.Engine) {
 			inspectionPoints.GET("/:id", controllers.GetInspectionPoint)
 			inspectionPoints.PUT("/:id", controllers.UpdateInspectionPoint)
 			inspectionPoints.DELETE("/:id", controllers.DeleteInspectionPoint)
+			inspectionPoints.GET("/:id/tag", controllers.GetInspectionPointTag)
+			inspectionPoints.POST("/:id/tag/regenerate", controllers.RegenerateInspectionPointTag)
+			inspectionPoints.GET("/:id/tag/payload", controllers.GetInspectionPointTagPayload)
 		}
 
 		// 巡检路线管理路由
@@ -44,7 +50,14 @@ func SetupRoutes(r *gin.Engine) {
 			inspectionRoutes.PUT("/:id", controllers.UpdateInspectionRoute)
 			inspectionRoutes.DELETE("/:id", controllers.DeleteInspectionRoute)
 			inspectionRoutes.POST("/:id/points", controllers.AddPointToRoute)
+			inspectionRoutes.PUT("/:id/points/order", controllers.ReorderRoutePoints)
+			inspectionRoutes.PUT("/:id/points/:pointId", controllers.UpdateRoutePoint)
 			inspectionRoutes.DELETE("/:id/points/:pointId", controllers.RemovePointFromRoute)
+			inspectionRoutes.GET("/:id/tags", controllers.GetRouteTagSheet)
+			inspectionRoutes.POST("/:id/optimize", controllers.OptimizeInspectionRoute)
+			inspectionRoutes.POST("/:id/versions", controllers.PublishInspectionRoute)
+			inspectionRoutes.GET("/:id/versions", controllers.ListInspectionRouteVersions)
+			inspectionRoutes.GET("/:id/versions/:version", controllers.GetInspectionRouteVersion)
 		}
 
 		// 巡检项路由
@@ -68,6 +81,9 @@ func SetupRoutes(r *gin.Engine) {
 			projects.PUT("/:id", controllers.UpdateProject)
 			projects.DELETE("/:id", controllers.DeleteProject)
 			projects.GET("/:id/tree", controllers.GetProjectTree)
+			projects.GET("/:id/blackoutDates", controllers.ListBlackoutDates)
+			projects.POST("/:id/blackoutDates", controllers.CreateBlackoutDate)
+			projects.DELETE("/:id/blackoutDates/:dateId", controllers.DeleteBlackoutDate)
 		}
 
 		// 巡检计划管理路由
@@ -79,6 +95,10 @@ func SetupRoutes(r *gin.Engine) {
 			inspectionPlans.PUT("/:id", controllers.UpdateInspectionPlan)
 			inspectionPlans.DELETE("/:id", controllers.DeleteInspectionPlan)
 			inspectionPlans.POST("/:id/trigger", controllers.TriggerInspectionPlan)
+			inspectionPlans.GET("/:id/occurrences", controllers.ListPlanOccurrences)
+			inspectionPlans.POST("/:id/pause", controllers.PauseInspectionPlan)
+			inspectionPlans.POST("/:id/resume", controllers.ResumeInspectionPlan)
+			inspectionPlans.POST("/:id/archive", controllers.ArchiveInspectionPlan)
 		}
 
 		// 巡检工单管理路由
@@ -86,10 +106,39 @@ func SetupRoutes(r *gin.Engine) {
 		{
 			inspectionOrders.GET("/", controllers.ListInspectionOrders)
 			inspectionOrders.GET("/:id", controllers.GetInspectionOrder)
+			inspectionOrders.GET("/:id/timeline", controllers.GetInspectionOrderTimeline)
 			inspectionOrders.POST("/:id/assign", controllers.AssignInspectionOrder)
+			inspectionOrders.POST("/:id/claim", controllers.ClaimInspectionOrder)
+			inspectionOrders.POST("/:id/reject", controllers.RejectInspectionOrder)
 			inspectionOrders.POST("/:id/start", controllers.StartInspectionOrder)
 			inspectionOrders.POST("/:id/complete", controllers.CompleteInspectionOrder)
+			inspectionOrders.POST("/:id/approve", controllers.ApproveInspectionOrder)
+			inspectionOrders.POST("/:id/sendBack", controllers.SendBackInspectionOrder)
+			inspectionOrders.POST("/:id/reopen", controllers.ReopenInspectionOrder)
+			inspectionOrders.POST("/:id/cancel", controllers.CancelInspectionOrder)
 			inspectionOrders.POST("/:id/points/:pointId/check", controllers.CheckInspectionPoint)
+			inspectionOrders.GET("/:id/attachments", controllers.ListOrderAttachments)
+			inspectionOrders.POST("/:id/attachments", controllers.UploadOrderAttachment)
+			inspectionOrders.POST("/:id/points/:pointId/attachments", controllers.UploadCheckAttachment)
+			inspectionOrders.POST("/:id/points/:pointId/items/:itemId/attachments", controllers.UploadItemAttachment)
 		}
+
+		// 整改单路由
+		defects := protected.Group("/defects")
+		{
+			defects.GET("/", controllers.ListDefects)
+			defects.GET("/:id", controllers.GetDefect)
+			defects.PUT("/:id", controllers.UpdateDefect)
+			defects.POST("/:id/start", controllers.StartDefectRepair)
+			defects.POST("/:id/resolve", controllers.ResolveDefect)
+			defects.POST("/:id/close", controllers.CloseDefect)
+		}
+
+		// 离线同步路由
+		protected.GET("/sync/package", controllers.DownloadOfflinePackage)
+		protected.POST("/sync", controllers.SyncOperations)
+
+		// 附件路由
+		protected.DELETE("/attachments/:id", controllers.DeleteAttachment)
 	}
 }
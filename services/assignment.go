@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAssigneeNoProjectAccess 指定的执行人无权访问计划所属项目
+var ErrAssigneeNoProjectAccess = errors.New("执行人无权访问该项目")
+
+// ValidatePlanAssignee 校验计划的固定执行人存在且有权访问计划所属项目
+func ValidatePlanAssignee(plan models.InspectionPlan) error {
+	if plan.FixedAssigneeID == nil {
+		return nil
+	}
+	_, err := loadAssignee(config.DB, *plan.FixedAssigneeID, plan.ProjectID)
+	return err
+}
+
+// loadAssignee 读取执行人并校验其有权访问项目
+func loadAssignee(db *gorm.DB, userID, projectID uint) (models.User, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrAssigneeNotFound
+		}
+		return user, err
+	}
+	// 未关联项目的用户为超级管理员，可以访问所有项目
+	if user.ProjectID != nil && *user.ProjectID != projectID {
+		return user, ErrAssigneeNoProjectAccess
+	}
+	return user, nil
+}
+
+// pickAssignee 按计划的分配策略为新工单选择执行人，轮流和按负载分配时同时推进计划的轮转进度
+func pickAssignee(tx *gorm.DB, plan models.InspectionPlan) (*uint, error) {
+	if plan.AssignmentStrategy == models.AssignmentStrategyUnassigned || plan.AssignmentStrategy == "" {
+		return nil, nil
+	}
+
+	var assignees []models.User
+	if err := tx.Model(&plan).Association("Assignees").Find(&assignees); err != nil {
+		return nil, err
+	}
+	sort.Slice(assignees, func(i, j int) bool { return assignees[i].ID < assignees[j].ID })
+
+	if plan.AssignmentStrategy == models.AssignmentStrategyFixed {
+		if plan.FixedAssigneeID != nil {
+			return plan.FixedAssigneeID, nil
+		}
+		if len(assignees) == 0 {
+			return nil, nil
+		}
+		return &assignees[0].ID, nil
+	}
+
+	if len(assignees) == 0 {
+		return nil, nil
+	}
+
+	// 读取并锁定最新的轮转进度，同一批次连续生成或并发生成时也能依次轮转
+	var current models.InspectionPlan
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "last_assignee_id").First(&current, plan.ID).Error; err != nil {
+		return nil, err
+	}
+
+	// 从上次分配的执行人之后开始轮转
+	start := 0
+	if current.LastAssigneeID != nil {
+		start = sort.Search(len(assignees), func(i int) bool { return assignees[i].ID > *current.LastAssigneeID }) % len(assignees)
+	}
+	candidates := make([]models.User, 0, len(assignees))
+	candidates = append(candidates, assignees[start:]...)
+	candidates = append(candidates, assignees[:start]...)
+
+	chosen := candidates[0].ID
+	if plan.AssignmentStrategy == models.AssignmentStrategyLeastLoaded {
+		loads, err := assigneeLoads(tx, candidates)
+		if err != nil {
+			return nil, err
+		}
+		// 负载相同时按轮转顺序取第一个，避免总是分配给同一人
+		for _, user := range candidates {
+			if loads[user.ID] < loads[chosen] {
+				chosen = user.ID
+			}
+		}
+	}
+
+	if err := tx.Model(&models.InspectionPlan{}).Where("id = ?", plan.ID).Update("last_assignee_id", chosen).Error; err != nil {
+		return nil, err
+	}
+	return &chosen, nil
+}
+
+// assigneeLoads 统计各执行人未完成的工单数
+func assigneeLoads(tx *gorm.DB, users []models.User) (map[uint]int64, error) {
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	var rows []struct {
+		AssigneeID uint
+		Count      int64
+	}
+	if err := tx.Model(&models.InspectionOrder{}).
+		Select("assignee_id, COUNT(*) AS count").
+		Where("assignee_id IN ? AND status IN ?", ids, OpenOrderStatuses).
+		Group("assignee_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	loads := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		loads[row.AssigneeID] = row.Count
+	}
+	return loads, nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/storage"
+	"go-inspect/utils"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// thumbnailSize 缩略图最长边的像素
+const thumbnailSize = 256
+
+var (
+	// ErrAttachmentTooLarge 附件超过大小限制
+	ErrAttachmentTooLarge = errors.New("附件超过大小限制")
+	// ErrAttachmentTypeNotAllowed 附件类型不允许上传
+	ErrAttachmentTypeNotAllowed = errors.New("不允许上传该类型的附件")
+	// ErrItemNotInPoint 巡检项不属于该巡检点
+	ErrItemNotInPoint = errors.New("巡检项不属于该巡检点")
+	// ErrOrderCancelled 工单已取消，不能再上传附件
+	ErrOrderCancelled = errors.New("巡检工单已取消")
+)
+
+// defaultAllowedTypes 未配置 storage.allowed_types 时允许上传的类型
+var defaultAllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "application/pdf"}
+
+// MaxAttachmentSize 返回单个附件的大小上限（字节）
+func MaxAttachmentSize() int64 {
+	if size := viper.GetInt64("storage.max_size"); size > 0 {
+		return size
+	}
+	return 10 << 20
+}
+
+func allowedAttachmentTypes() []string {
+	if types := viper.GetStringSlice("storage.allowed_types"); len(types) > 0 {
+		return types
+	}
+	return defaultAllowedTypes
+}
+
+// AttachmentTarget 附件归属：只有 OrderID 时为工单附件，有 PointID 时为该点确认的附件，再有 ItemID 时为巡检项附件
+type AttachmentTarget struct {
+	OrderID uint
+	PointID uint
+	ItemID  uint
+}
+
+// SaveAttachment 校验大小和实际内容类型后保存附件，图片同时生成缩略图
+func SaveAttachment(target AttachmentTarget, uploaderID uint, fileName string, data []byte) (*models.Attachment, error) {
+	if int64(len(data)) > MaxAttachmentSize() {
+		return nil, ErrAttachmentTooLarge
+	}
+	// 以文件内容识别类型，不信任客户端声明的类型
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !slices.Contains(allowedAttachmentTypes(), contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+	}
+	// 图片先按头部声明的尺寸拒绝像素数过大的文件，避免解码时耗尽内存
+	if strings.HasPrefix(contentType, "image/") && errors.Is(utils.CheckImageSize(data), utils.ErrImageTooLarge) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentTooLarge, utils.ErrImageTooLarge)
+	}
+
+	attachment := models.Attachment{
+		OrderID:      target.OrderID,
+		FileName:     path.Base("/" + fileName),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		UploadedByID: uploaderID,
+	}
+	if err := resolveAttachmentTarget(target, &attachment); err != nil {
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	dir := fmt.Sprintf("orders/%d", target.OrderID)
+	attachment.StorageKey = fmt.Sprintf("%s/%s%s", dir, name, strings.ToLower(path.Ext(attachment.FileName)))
+	if err := storage.Default.Put(attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(contentType, "image/") {
+		if thumb, err := utils.Thumbnail(data, thumbnailSize); err != nil {
+			log.Printf("附件 %s 生成缩略图失败: %v", attachment.StorageKey, err)
+		} else {
+			thumbKey := fmt.Sprintf("%s/%s_thumb.jpg", dir, name)
+			if err := storage.Default.Put(thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+				log.Printf("附件 %s 保存缩略图失败: %v", attachment.StorageKey, err)
+			} else {
+				attachment.ThumbnailKey = thumbKey
+			}
+		}
+	}
+
+	if err := config.DB.Create(&attachment).Error; err != nil {
+		removeAttachmentFiles(attachment)
+		return nil, err
+	}
+	SignAttachmentURLs(&attachment)
+	return &attachment, nil
+}
+
+// resolveAttachmentTarget 校验附件归属的工单、巡检点确认和巡检项
+func resolveAttachmentTarget(target AttachmentTarget, attachment *models.Attachment) error {
+	var order models.InspectionOrder
+	if err := config.DB.First(&order, target.OrderID).Error; err != nil {
+		return err
+	}
+	if target.PointID == 0 {
+		if order.Status == models.OrderStatusCancelled {
+			return ErrOrderCancelled
+		}
+		return nil
+	}
+
+	// 巡检点和巡检项的附件是巡检结果的一部分，只能由执行人在巡检过程中上传
+	if order.AssigneeID == nil || *order.AssigneeID != attachment.UploadedByID {
+		return ErrOrderActorNotAllowed
+	}
+	if order.Status != models.OrderStatusInProgress {
+		return ErrOrderNotInProgress
+	}
+	var check models.InspectionPointCheck
+	if err := config.DB.Where("order_id = ? AND point_id = ?", order.ID, target.PointID).First(&check).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCheckNotFound
+		}
+		return err
+	}
+	attachment.CheckID = &check.ID
+
+	if target.ItemID != 0 {
+		version, err := orderRouteVersion(config.DB, order)
+		if err != nil {
+			return err
+		}
+		point, err := orderPoint(config.DB, version, target.PointID)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(point.Items, func(item models.InspectionItem) bool { return item.ID == target.ItemID }) {
+			return ErrItemNotInPoint
+		}
+		attachment.ItemID = &target.ItemID
+	}
+	return nil
+}
+
+// photoItemIDs 返回该巡检点确认中已上传照片的巡检项
+func photoItemIDs(tx *gorm.DB, checkID uint) (map[uint]bool, error) {
+	var itemIDs []uint
+	if err := tx.Model(&models.Attachment{}).Where("check_id = ? AND item_id IS NOT NULL AND content_type LIKE ?", checkID, "image/%").Distinct().Pluck("item_id", &itemIDs).Error; err != nil {
+		return nil, err
+	}
+	photos := make(map[uint]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		photos[id] = true
+	}
+	return photos, nil
+}
+
+// DeleteAttachment 删除附件记录及存储的文件，只有执行人可以在巡检过程中删除；
+// 删除拍照类巡检项的最后一张照片后，已确认的巡检点退回待确认，需要重新拍照提交
+func DeleteAttachment(attachment models.Attachment, userID uint) error {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.InspectionOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, attachment.OrderID).Error; err != nil {
+			return err
+		}
+		if order.AssigneeID == nil || *order.AssigneeID != userID {
+			return ErrOrderActorNotAllowed
+		}
+		if order.Status != models.OrderStatusInProgress {
+			return ErrOrderNotInProgress
+		}
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		if attachment.CheckID == nil || attachment.ItemID == nil || !strings.HasPrefix(attachment.ContentType, "image/") {
+			return nil
+		}
+
+		var check models.InspectionPointCheck
+		if err := tx.First(&check, *attachment.CheckID).Error; err != nil {
+			return err
+		}
+		if check.Status == models.CheckStatusPending {
+			return nil
+		}
+		photos, err := photoItemIDs(tx, check.ID)
+		if err != nil || photos[*attachment.ItemID] {
+			return err
+		}
+		version, err := orderRouteVersion(tx, order)
+		if err != nil {
+			return err
+		}
+		point, err := orderPoint(tx, version, check.PointID)
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(point.Items, func(item models.InspectionItem) bool { return item.ID == *attachment.ItemID })
+		if i < 0 || point.Items[i].Type != models.ItemTypePhoto {
+			return nil
+		}
+
+		if err := tx.Model(&check).Update("status", models.CheckStatusPending).Error; err != nil {
+			return err
+		}
+		if err := refreshCompletedChecks(tx, &order); err != nil {
+			return err
+		}
+		return saveOrder(tx, &order)
+	})
+	if err != nil {
+		return err
+	}
+	removeAttachmentFiles(attachment)
+	return nil
+}
+
+func removeAttachmentFiles(attachment models.Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Default.Delete(key); err != nil {
+			log.Printf("删除附件文件 %s 失败: %v", key, err)
+		}
+	}
+}
+
+// AttachmentResource 附件下载签名对应的资源名，thumbnail 为 true 时为缩略图
+func AttachmentResource(id uint, thumbnail bool) string {
+	if thumbnail {
+		return fmt.Sprintf("attachment:%d:thumbnail", id)
+	}
+	return fmt.Sprintf("attachment:%d", id)
+}
+
+// SignAttachmentURLs 为附件生成限时下载地址，有效期由 storage.url_ttl 配置
+func SignAttachmentURLs(attachment *models.Attachment) {
+	ttl := viper.GetDuration("storage.url_ttl")
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	expires := time.Now().Add(ttl)
+
+	attachment.URL = signedAttachmentURL(attachment.ID, false, expires)
+	if attachment.ThumbnailKey != "" {
+		attachment.ThumbnailURL = signedAttachmentURL(attachment.ID, true, expires)
+	}
+}
+
+func signedAttachmentURL(id uint, thumbnail bool, expires time.Time) string {
+	url := fmt.Sprintf("/api/attachments/%d/download?expires=%d&signature=%s", id, expires.Unix(), utils.SignResource(AttachmentResource(id, thumbnail), expires))
+	if thumbnail {
+		url += "&thumbnail=1"
+	}
+	return url
+}
+
+func randomName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"go-inspect/models"
+	"go-inspect/storage"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// testPNG 生成一张小图片
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveAttachmentOnlyByAssignee(t *testing.T) {
+	db := setupTestDB(t)
+	prev := storage.Default
+	storage.Default = storage.NewLocalStorage(t.TempDir())
+	defer func() { storage.Default = prev }()
+
+	f := newTestFixture(t, db, 1, models.InspectionItem{Title: "铭牌照片", ExecutionMethod: "拍照", Type: models.ItemTypePhoto})
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID := f.points[0].Items[0].ID
+	photo := testPNG(t)
+	pointTarget := AttachmentTarget{OrderID: order.ID, PointID: f.points[0].ID}
+	itemTarget := AttachmentTarget{OrderID: order.ID, PointID: f.points[0].ID, ItemID: itemID}
+
+	// 开始巡检前执行人也不能上传巡检点附件
+	if _, err := SaveAttachment(itemTarget, f.assignee.ID, "a.png", photo); !errors.Is(err, ErrOrderNotInProgress) {
+		t.Errorf("upload before start: error = %v, want ErrOrderNotInProgress", err)
+	}
+	if _, err := StartOrder(order.ID, f.assigneeActor()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []AttachmentTarget{pointTarget, itemTarget} {
+		if _, err := SaveAttachment(target, f.assigner.ID, "a.png", photo); !errors.Is(err, ErrOrderActorNotAllowed) {
+			t.Errorf("upload by another project member to %+v: error = %v, want ErrOrderActorNotAllowed", target, err)
+		}
+	}
+	// 其他成员上传的照片不能满足拍照类巡检项
+	var check models.InspectionPointCheck
+	if err := db.Where("order_id = ? AND point_id = ?", order.ID, f.points[0].ID).First(&check).Error; err != nil {
+		t.Fatal(err)
+	}
+	if photos, err := photoItemIDs(db, check.ID); err != nil || photos[itemID] {
+		t.Errorf("photo items after rejected uploads = %v, %v, want none", photos, err)
+	}
+
+	attachment, err := SaveAttachment(itemTarget, f.assignee.ID, "a.png", photo)
+	if err != nil {
+		t.Fatalf("upload by assignee: %v", err)
+	}
+	if attachment.ItemID == nil || *attachment.ItemID != itemID || attachment.CheckID == nil {
+		t.Errorf("attachment target = check %v item %v, want item %d", attachment.CheckID, attachment.ItemID, itemID)
+	}
+
+	// 工单附件仍对项目成员开放
+	if _, err := SaveAttachment(AttachmentTarget{OrderID: order.ID}, f.assigner.ID, "a.png", photo); err != nil {
+		t.Errorf("order attachment by project member: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"go-inspect/config"
+	"go-inspect/models"
+	"time"
+)
+
+// MaxBlackoutShiftDays 顺延或提前时最多查找的天数，超过则放弃本次触发
+const MaxBlackoutShiftDays = 31
+
+// Calendar 停检日集合，键为 2006-01-02 格式的日期
+type Calendar map[string]bool
+
+// IsBlackout 判断 t 所在日期（按 t 的时区）是否为停检日
+func (cal Calendar) IsBlackout(t time.Time) bool {
+	return cal[t.Format(time.DateOnly)]
+}
+
+// ProjectCalendar 返回项目生效的停检日：项目自身的停检日，以及各级上级项目中作用于子项目的停检日
+func ProjectCalendar(projectID uint) (Calendar, error) {
+	cal := Calendar{}
+
+	var own []models.BlackoutDate
+	if err := config.DB.Where("project_id = ?", projectID).Find(&own).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range own {
+		cal[d.Date] = true
+	}
+
+	inherited, err := InheritedBlackoutDates(projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range inherited {
+		cal[d.Date] = true
+	}
+	return cal, nil
+}
+
+// InheritedBlackoutDates 按日期返回上级项目中作用于子项目的停检日
+func InheritedBlackoutDates(projectID uint) ([]models.BlackoutDate, error) {
+	ancestorIDs, err := projectAncestorIDs(projectID)
+	if err != nil || len(ancestorIDs) == 0 {
+		return nil, err
+	}
+	var inherited []models.BlackoutDate
+	err = config.DB.Where("project_id IN ? AND apply_to_children = ?", ancestorIDs, true).Order("date").Find(&inherited).Error
+	return inherited, err
+}
+
+// projectAncestorIDs 沿 ParentID 向上返回项目的所有上级项目ID
+func projectAncestorIDs(projectID uint) ([]uint, error) {
+	var ids []uint
+	var project models.Project
+	if err := config.DB.First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	for project.ParentID != nil {
+		// 防止数据异常形成环
+		if len(ids) > 32 {
+			break
+		}
+		ids = append(ids, *project.ParentID)
+		if err := config.DB.First(&project, *project.ParentID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// ScheduledTime 按计划的停检日规则调整触发时刻，返回实际执行时刻；规则为跳过或找不到工作日时返回 false
+func ScheduledTime(plan models.InspectionPlan, occurrence time.Time, cal Calendar) (time.Time, bool) {
+	if !cal.IsBlackout(occurrence) {
+		return occurrence, true
+	}
+
+	step := 0
+	switch plan.BlackoutRule {
+	case models.BlackoutRuleNextWorkday:
+		step = 1
+	case models.BlackoutRulePreviousWorkday:
+		step = -1
+	default:
+		return time.Time{}, false
+	}
+
+	// AddDate 保持时区内的钟点不变
+	for i := 1; i <= MaxBlackoutShiftDays; i++ {
+		shifted := occurrence.AddDate(0, 0, step*i)
+		if !cal.IsBlackout(shifted) {
+			return shifted, true
+		}
+	}
+	return time.Time{}, false
+}
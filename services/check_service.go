@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrOrderNotInProgress 工单未在巡检中，不能确认巡检点
+	ErrOrderNotInProgress = errors.New("巡检工单未在巡检中")
+	// ErrCheckNotFound 工单中没有该巡检点的确认记录
+	ErrCheckNotFound = errors.New("巡检点确认记录不存在")
+	// ErrInvalidCheckInput 巡检结果不完整或不合法
+	ErrInvalidCheckInput = errors.New("巡检结果不合法")
+)
+
+// ItemResultInput 单个巡检项的结果，多选类巡检项通过 Values 填写所选选项
+type ItemResultInput struct {
+	ItemID  uint               `json:"item_id" binding:"required"`
+	Status  models.CheckStatus `json:"status"`
+	Value   string             `json:"value"`
+	Values  []string           `json:"values"`
+	Comment string             `json:"comment"`
+}
+
+// CheckInput 确认巡检点的参数；点位有巡检项时需逐项填写 Items，点位状态由各项结果得出
+type CheckInput struct {
+	Status          models.CheckStatus `json:"status"`
+	Comment         string             `json:"comment"`
+	Items           []ItemResultInput  `json:"items"`
+	ExpectedVersion int                `json:"-"`          // 客户端读取到的工单版本号，非 0 时必须与当前版本一致
+	CheckedAt       time.Time          `json:"-"`          // 离线同步时为客户端记录的确认时间，零值表示当前时间
+	TagCode         string             `json:"tag_code"`   // 扫描巡检点标签得到的内容
+	ScannedAt       *time.Time         `json:"scanned_at"` // 扫描标签的时间，为空时取当前时间
+	Latitude        *float64           `json:"latitude"`   // 确认时的 GPS 位置
+	Longitude       *float64           `json:"longitude"`
+	Accuracy        *float64           `json:"accuracy"` // GPS 精度（米）
+}
+
+// CheckPoint 在事务中保存巡检点及其各巡检项的结果，只有工单的执行人可以确认；已确认的点位可以重新提交修改，
+// 工单的已完成点位数按确认记录重新统计，不会重复计数
+func CheckPoint(orderID, pointID uint, actor OrderActor, input CheckInput) (*models.InspectionPointCheck, error) {
+	var check models.InspectionPointCheck
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var order models.InspectionOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan").First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if !actor.ProjectMember || order.AssigneeID == nil || *order.AssigneeID != actor.UserID {
+			return ErrOrderActorNotAllowed
+		}
+		if err := checkOrderVersion(order, input.ExpectedVersion); err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusInProgress {
+			return ErrOrderNotInProgress
+		}
+
+		if err := tx.Where("order_id = ? AND point_id = ?", orderID, pointID).First(&check).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCheckNotFound
+			}
+			return err
+		}
+
+		// 按工单生成时的路线版本校验顺序和巡检项，之后对路线和巡检项的修改不影响该工单
+		version, err := orderRouteVersion(tx, order)
+		if err != nil {
+			return err
+		}
+		if err := checkSequence(tx, order, version, check); err != nil {
+			return err
+		}
+
+		point, err := orderPoint(tx, version, pointID)
+		if err != nil {
+			return err
+		}
+
+		// 校验现场扫描的标签，证明执行人到过该巡检点；有效期按服务端收到的时间判断
+		receivedAt := time.Now()
+		if err := VerifyTagScan(point, input.TagCode, receivedAt); err != nil {
+			return err
+		}
+		scannedAt := receivedAt
+		if input.ScannedAt != nil && input.ScannedAt.Before(scannedAt) {
+			scannedAt = *input.ScannedAt
+		}
+		if input.TagCode != "" {
+			check.ScannedAt = &scannedAt
+		}
+
+		// 校验确认位置是否在巡检点的地理围栏内
+		if err := checkGeofence(point, &check, input.Latitude, input.Longitude, input.Accuracy); err != nil {
+			return err
+		}
+
+		photos, err := photoItemIDs(tx, check.ID)
+		if err != nil {
+			return err
+		}
+
+		status, results, err := evaluateItems(point, input, photos)
+		if err != nil {
+			return err
+		}
+
+		if len(results) > 0 {
+			for i := range results {
+				results[i].OrderID = order.ID
+				results[i].CheckID = check.ID
+				results[i].PointID = point.ID
+			}
+			// 重新提交时覆盖之前的结果
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "check_id"}, {Name: "item_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"status", "value", "reading", "out_of_range", "comment", "updated_at", "deleted_at"}),
+			}).Create(&results).Error; err != nil {
+				return err
+			}
+		}
+
+		checkedAt := input.CheckedAt
+		if checkedAt.IsZero() {
+			checkedAt = time.Now()
+		}
+		check.Status = status
+		check.Comment = input.Comment
+		check.CheckedAt = &checkedAt
+		check.Results = results
+		if err := tx.Omit(clause.Associations).Save(&check).Error; err != nil {
+			return err
+		}
+
+		// 不合格的点位和巡检项自动生成整改单；复检工单中正在复检的整改单尚未关闭，不会重复生成，
+		// 其结果在结单时回写到原整改单，复检中新发现的其他不合格项照常生成整改单
+		if err := openDefects(tx, order, check, point); err != nil {
+			return err
+		}
+
+		if err := refreshCompletedChecks(tx, &order); err != nil {
+			return err
+		}
+		return saveOrder(tx, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// evaluateItems 按类型校验各巡检项的结果并得出点位状态：任一项不合格即不合格；点位没有巡检项时使用直接填写的状态。
+// photos 为已上传照片的巡检项
+func evaluateItems(point models.InspectionPoint, input CheckInput, photos map[uint]bool) (models.CheckStatus, []models.InspectionItemResult, error) {
+	if len(point.Items) == 0 {
+		if !isFinalCheckStatus(input.Status) {
+			return "", nil, fmt.Errorf("%w：状态必须为 passed 或 failed", ErrInvalidCheckInput)
+		}
+		return input.Status, nil, nil
+	}
+
+	answers := make(map[uint]ItemResultInput, len(input.Items))
+	for _, answer := range input.Items {
+		if _, dup := answers[answer.ItemID]; dup {
+			return "", nil, fmt.Errorf("%w：巡检项 %d 重复填写", ErrInvalidCheckInput, answer.ItemID)
+		}
+		answers[answer.ItemID] = answer
+	}
+
+	status := models.CheckStatusPassed
+	results := make([]models.InspectionItemResult, 0, len(point.Items))
+	for _, item := range point.Items {
+		answer, ok := answers[item.ID]
+		if !ok {
+			return "", nil, fmt.Errorf("%w：巡检项「%s」未填写", ErrInvalidCheckInput, item.Title)
+		}
+		delete(answers, item.ID)
+		result, err := EvaluateItemResult(item, answer, photos[item.ID])
+		if err != nil {
+			return "", nil, err
+		}
+		if result.Status == models.CheckStatusFailed {
+			status = models.CheckStatusFailed
+		}
+		results = append(results, result)
+	}
+	if len(answers) > 0 {
+		// 有多个多余的巡检项时固定报告ID最小的一个
+		extra := make([]uint, 0, len(answers))
+		for itemID := range answers {
+			extra = append(extra, itemID)
+		}
+		slices.Sort(extra)
+		return "", nil, fmt.Errorf("%w：巡检项 %d 不属于该巡检点", ErrInvalidCheckInput, extra[0])
+	}
+	return status, results, nil
+}
+
+// countCompletedChecks 统计工单中已确认（合格或不合格）的巡检点数量
+func countCompletedChecks(tx *gorm.DB, orderID uint) (int64, error) {
+	var count int64
+	err := tx.Model(&models.InspectionPointCheck{}).Where("order_id = ? AND status <> ?", orderID, models.CheckStatusPending).Count(&count).Error
+	return count, err
+}
+
+// refreshCompletedChecks 按确认记录重新统计工单的已完成点位数
+func refreshCompletedChecks(tx *gorm.DB, order *models.InspectionOrder) error {
+	completed, err := countCompletedChecks(tx, order.ID)
+	if err != nil {
+		return err
+	}
+	order.CompletedChecks = int(completed)
+	return nil
+}
+
+func isFinalCheckStatus(status models.CheckStatus) bool {
+	return status == models.CheckStatusPassed || status == models.CheckStatusFailed
+}
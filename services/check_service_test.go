@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/models"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestEvaluateItemsExtraAnswers(t *testing.T) {
+	point := models.InspectionPoint{Items: []models.InspectionItem{
+		{Model: gorm.Model{ID: 1}, Title: "外观", Type: models.ItemTypeBoolean},
+	}}
+	input := CheckInput{Items: []ItemResultInput{
+		{ItemID: 1, Status: models.CheckStatusPassed},
+		{ItemID: 9, Status: models.CheckStatusPassed},
+		{ItemID: 7, Status: models.CheckStatusPassed},
+		{ItemID: 8, Status: models.CheckStatusPassed},
+	}}
+	for i := 0; i < 10; i++ {
+		_, _, err := evaluateItems(point, input, nil)
+		if !errors.Is(err, ErrInvalidCheckInput) || !strings.Contains(err.Error(), "巡检项 7 不属于") {
+			t.Fatalf("error = %v, want item 7 reported", err)
+		}
+	}
+}
+
+func TestCheckPointCompletedChecks(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 2)
+	order := f.startedOrder(t)
+	pointID := f.points[0].ID
+
+	steps := []struct {
+		name   string
+		status models.CheckStatus
+	}{
+		{"first submission", models.CheckStatusPassed},
+		{"same point submitted again", models.CheckStatusPassed},
+		{"completed check edited", models.CheckStatusFailed},
+	}
+	for _, step := range steps {
+		if _, err := CheckPoint(order.ID, pointID, f.assigneeActor(), CheckInput{Status: step.status}); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		got := reload[models.InspectionOrder](t, order.ID)
+		if got.CompletedChecks != 1 {
+			t.Errorf("%s: CompletedChecks = %d, want 1", step.name, got.CompletedChecks)
+		}
+	}
+
+	if _, err := CheckPoint(order.ID, f.points[1].ID, f.assigneeActor(), CheckInput{Status: models.CheckStatusPassed}); err != nil {
+		t.Fatal(err)
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.CompletedChecks != 2 || got.TotalChecks != 2 {
+		t.Errorf("CompletedChecks = %d/%d, want 2/2", got.CompletedChecks, got.TotalChecks)
+	}
+}
+
+func TestCheckPointStaleVersion(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order := f.startedOrder(t)
+
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), CheckInput{Status: models.CheckStatusPassed, ExpectedVersion: order.Version - 1}); !errors.Is(err, ErrOrderVersionConflict) {
+		t.Errorf("check with stale version: error = %v, want ErrOrderVersionConflict", err)
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.CompletedChecks != 0 {
+		t.Errorf("CompletedChecks after rejected check = %d, want 0", got.CompletedChecks)
+	}
+}
+
+func TestSaveOrderStaleVersion(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order := f.startedOrder(t)
+
+	// 两个操作读取到同一版本，后保存的一方被拒绝
+	first, second := *order, *order
+	first.CompletedChecks = 1
+	if err := saveOrder(db, &first); err != nil {
+		t.Fatal(err)
+	}
+	second.CompletedChecks = 5
+	if err := saveOrder(db, &second); !errors.Is(err, ErrOrderVersionConflict) {
+		t.Errorf("save with stale version: error = %v, want ErrOrderVersionConflict", err)
+	}
+	if second.Version != order.Version {
+		t.Errorf("version after rejected save = %d, want %d", second.Version, order.Version)
+	}
+
+	got := reload[models.InspectionOrder](t, order.ID)
+	if got.CompletedChecks != 1 || got.Version != order.Version+1 {
+		t.Errorf("saved order CompletedChecks = %d, version = %d, want 1 and %d", got.CompletedChecks, got.Version, order.Version+1)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"go-inspect/config"
+	"go-inspect/models"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用内存 SQLite 作为 config.DB 并迁移全部模型，测试结束后恢复
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库按连接隔离，所有操作共用一个连接
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.SetupJoinTable(&models.InspectionRoute{}, "Points", &models.RoutePoint{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Project{}, &models.User{}, &models.InspectionItem{}, &models.InspectionPoint{}, &models.InspectionRoute{}, &models.InspectionPlan{}, &models.InspectionOrder{}, &models.InspectionPointCheck{}, &models.BlackoutDate{}, &models.OrderEvent{}, &models.InspectionItemResult{}, &models.Attachment{}, &models.Defect{}, &models.SyncOperation{}, &models.RouteVersion{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 测试中不要求扫描标签
+	viper.Set("inspection.tag.required", false)
+	prev := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = prev
+		viper.Set("inspection.tag.required", nil)
+		sqlDB.Close()
+	})
+	return db
+}
+
+// testFixture 一个项目下的派单人、执行人、路线和计划
+type testFixture struct {
+	project  models.Project
+	assigner models.User
+	assignee models.User
+	points   []models.InspectionPoint
+	route    models.InspectionRoute
+	plan     models.InspectionPlan
+}
+
+// newTestFixture 创建包含 pointCount 个巡检点的路线及其手动计划，items 挂到第一个巡检点上
+func newTestFixture(t *testing.T, db *gorm.DB, pointCount int, items ...models.InspectionItem) *testFixture {
+	t.Helper()
+	f := &testFixture{}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f.project = models.Project{Name: "测试项目"}
+	must(db.Create(&f.project).Error)
+	f.assigner = models.User{Username: "assigner", Email: "assigner@example.com", Password: "x", Role: models.RoleSupervisor, ProjectID: &f.project.ID}
+	must(db.Create(&f.assigner).Error)
+	f.assignee = models.User{Username: "assignee", Email: "assignee@example.com", Password: "x", Role: models.RoleInspector, ProjectID: &f.project.ID}
+	must(db.Create(&f.assignee).Error)
+
+	f.route = models.InspectionRoute{Name: "测试路线", ProjectID: f.project.ID}
+	must(db.Omit(clause.Associations).Create(&f.route).Error)
+	for i := 0; i < pointCount; i++ {
+		point := models.InspectionPoint{Name: "巡检点"}
+		if i == 0 {
+			point.Items = items
+		}
+		must(db.Create(&point).Error)
+		f.points = append(f.points, point)
+		must(db.Create(&models.RoutePoint{InspectionRouteID: f.route.ID, InspectionPointID: point.ID, Position: i + 1}).Error)
+	}
+
+	f.plan = models.InspectionPlan{
+		Name:               "测试计划",
+		ProjectID:          f.project.ID,
+		RouteID:            f.route.ID,
+		TriggerType:        models.TriggerTypeManual,
+		Status:             models.PlanStatusActive,
+		AssignerID:         f.assigner.ID,
+		AssignmentStrategy: models.AssignmentStrategyFixed,
+		FixedAssigneeID:    &f.assignee.ID,
+	}
+	must(db.Omit(clause.Associations).Create(&f.plan).Error)
+	return f
+}
+
+// assigneeActor 执行人
+func (f *testFixture) assigneeActor() OrderActor {
+	return OrderActor{UserID: f.assignee.ID, UserRole: f.assignee.Role, ProjectMember: true}
+}
+
+// assignerActor 计划派单人
+func (f *testFixture) assignerActor() OrderActor {
+	return OrderActor{UserID: f.assigner.ID, UserRole: f.assigner.Role, ProjectMember: true}
+}
+
+// startedOrder 手动生成工单并由执行人开始巡检
+func (f *testFixture) startedOrder(t *testing.T) *models.InspectionOrder {
+	t.Helper()
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order, err = StartOrder(order.ID, f.assigneeActor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+// reload 重新读取记录
+func reload[T any](t *testing.T, id uint) T {
+	t.Helper()
+	var record T
+	if err := config.DB.First(&record, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
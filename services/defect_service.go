@@ -0,0 +1,419 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefectAction 整改单状态迁移动作
+type DefectAction string
+
+const (
+	DefectActionStart   DefectAction = "start"   // 开始整改
+	DefectActionResolve DefectAction = "resolve" // 整改完成，提交验证
+	DefectActionVerify  DefectAction = "verify"  // 复检合格，关闭整改单
+	DefectActionReject  DefectAction = "reject"  // 复检不合格，退回继续整改
+	DefectActionClose   DefectAction = "close"   // 关闭未整改的整改单（如误报）
+)
+
+// DefectRole 操作人相对整改单的角色
+type DefectRole string
+
+const (
+	DefectRoleSystem      DefectRole = "system"      // 复检工单结单时自动处理
+	DefectRoleResponsible DefectRole = "responsible" // 整改负责人
+	DefectRoleManager     DefectRole = "manager"     // 项目负责人、计划派单人或具有审核角色的项目成员
+)
+
+type defectTransition struct {
+	from  []models.DefectStatus
+	to    models.DefectStatus
+	roles []DefectRole
+}
+
+var defectTransitions = map[DefectAction]defectTransition{
+	DefectActionStart: {
+		from:  []models.DefectStatus{models.DefectStatusOpen},
+		to:    models.DefectStatusInRepair,
+		roles: []DefectRole{DefectRoleResponsible, DefectRoleManager},
+	},
+	DefectActionResolve: {
+		from:  []models.DefectStatus{models.DefectStatusInRepair},
+		to:    models.DefectStatusPendingVerification,
+		roles: []DefectRole{DefectRoleResponsible},
+	},
+	// 已整改的整改单只能由复检工单的结果关闭或退回
+	DefectActionVerify: {
+		from:  []models.DefectStatus{models.DefectStatusPendingVerification},
+		to:    models.DefectStatusClosed,
+		roles: []DefectRole{DefectRoleSystem},
+	},
+	DefectActionReject: {
+		from:  []models.DefectStatus{models.DefectStatusPendingVerification},
+		to:    models.DefectStatusInRepair,
+		roles: []DefectRole{DefectRoleSystem},
+	},
+	DefectActionClose: {
+		from:  []models.DefectStatus{models.DefectStatusOpen},
+		to:    models.DefectStatusClosed,
+		roles: []DefectRole{DefectRoleManager},
+	},
+}
+
+// defaultDefectDeadlines 未配置 inspection.defect.deadlines 时各严重程度的整改期限
+var defaultDefectDeadlines = map[models.DefectSeverity]time.Duration{
+	models.DefectSeverityCritical: 24 * time.Hour,
+	models.DefectSeverityHigh:     72 * time.Hour,
+	models.DefectSeverityMedium:   7 * 24 * time.Hour,
+	models.DefectSeverityLow:      14 * 24 * time.Hour,
+}
+
+var (
+	// ErrDefectActorNotAllowed 操作人无权处理该整改单
+	ErrDefectActorNotAllowed = errors.New("无权对该整改单执行此操作")
+	// ErrDefectClosed 整改单已关闭
+	ErrDefectClosed = errors.New("整改单已关闭")
+	// ErrInvalidSeverity 严重程度无效
+	ErrInvalidSeverity = errors.New("无效的严重程度")
+)
+
+// DefectTransitionError 整改单当前状态不允许执行该动作
+type DefectTransitionError struct {
+	Action DefectAction
+	From   models.DefectStatus
+}
+
+func (e *DefectTransitionError) Error() string {
+	return fmt.Sprintf("整改单当前状态为 %s，不允许执行 %s", e.From, e.Action)
+}
+
+// ValidSeverity 判断严重程度是否有效
+func ValidSeverity(severity models.DefectSeverity) bool {
+	_, ok := defaultDefectDeadlines[severity]
+	return ok
+}
+
+// DefectDeadline 按严重程度计算整改期限
+func DefectDeadline(severity models.DefectSeverity, from time.Time) time.Time {
+	deadline := viper.GetDuration("inspection.defect.deadlines." + string(severity))
+	if deadline <= 0 {
+		deadline = defaultDefectDeadlines[severity]
+	}
+	return from.Add(deadline)
+}
+
+// openDefects 为不合格的巡检点或巡检项生成整改单；同一点位、巡检项已有未关闭的整改单时不重复生成
+func openDefects(tx *gorm.DB, order models.InspectionOrder, check models.InspectionPointCheck, point models.InspectionPoint) error {
+	if check.Status != models.CheckStatusFailed {
+		return nil
+	}
+
+	var defects []models.Defect
+	if len(check.Results) == 0 {
+		defects = append(defects, models.Defect{
+			Title:       fmt.Sprintf("巡检点「%s」不合格", point.Name),
+			Description: check.Comment,
+			Severity:    models.DefectSeverityMedium,
+		})
+	}
+	for _, result := range check.Results {
+		if result.Status != models.CheckStatusFailed {
+			continue
+		}
+		itemID := result.ItemID
+		title := fmt.Sprintf("巡检点「%s」巡检项 %d 不合格", point.Name, itemID)
+		if i := slices.IndexFunc(point.Items, func(item models.InspectionItem) bool { return item.ID == itemID }); i >= 0 {
+			title = fmt.Sprintf("巡检点「%s」的「%s」不合格", point.Name, point.Items[i].Title)
+		}
+		severity := models.DefectSeverityMedium
+		var details []string
+		if result.Value != "" {
+			details = append(details, "记录值："+result.Value)
+		}
+		if result.OutOfRange {
+			// 读数超限通常需要尽快处理
+			severity = models.DefectSeverityHigh
+			details = append(details, "读数超出允许范围")
+		}
+		if result.Comment != "" {
+			details = append(details, result.Comment)
+		}
+		defects = append(defects, models.Defect{
+			ItemID:      &itemID,
+			Title:       title,
+			Description: strings.Join(details, "；"),
+			Severity:    severity,
+		})
+	}
+
+	var project models.Project
+	if err := tx.First(&project, order.Plan.ProjectID).Error; err != nil {
+		return err
+	}
+	responsibleID := project.OwnerID
+	if responsibleID == nil && order.Plan.AssignerID != 0 {
+		responsibleID = &order.Plan.AssignerID
+	}
+
+	now := time.Now()
+	for _, defect := range defects {
+		query := tx.Model(&models.Defect{}).Where("point_id = ? AND status <> ?", point.ID, models.DefectStatusClosed)
+		if defect.ItemID != nil {
+			query = query.Where("item_id = ?", *defect.ItemID)
+		} else {
+			query = query.Where("item_id IS NULL")
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		dueAt := DefectDeadline(defect.Severity, now)
+		defect.ProjectID = order.Plan.ProjectID
+		defect.OrderID = order.ID
+		defect.CheckID = check.ID
+		defect.PointID = point.ID
+		defect.Status = models.DefectStatusOpen
+		defect.ReportedByID = order.AssigneeID
+		defect.ResponsibleID = responsibleID
+		defect.DueAt = &dueAt
+		if err := tx.Create(&defect).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defectRoles 返回操作人相对整改单的所有角色
+func defectRoles(tx *gorm.DB, defect models.Defect, actor OrderActor) ([]DefectRole, error) {
+	if actor.UserID == 0 {
+		return []DefectRole{DefectRoleSystem}, nil
+	}
+	var roles []DefectRole
+	if defect.ResponsibleID != nil && *defect.ResponsibleID == actor.UserID {
+		roles = append(roles, DefectRoleResponsible)
+	}
+	if !actor.ProjectMember {
+		return roles, nil
+	}
+	if actor.UserRole != "" && actor.UserRole == reviewerRole() {
+		return append(roles, DefectRoleManager), nil
+	}
+
+	var project models.Project
+	if err := tx.First(&project, defect.ProjectID).Error; err != nil {
+		return nil, err
+	}
+	var order models.InspectionOrder
+	if err := tx.Preload("Plan").First(&order, defect.OrderID).Error; err != nil {
+		return nil, err
+	}
+	if (project.OwnerID != nil && *project.OwnerID == actor.UserID) || order.Plan.AssignerID == actor.UserID {
+		roles = append(roles, DefectRoleManager)
+	}
+	return roles, nil
+}
+
+// TransitionDefect 在事务中锁定整改单，校验状态和操作人后执行迁移；
+// apply 用于在同一事务中完成迁移附带的修改，返回错误时整个迁移回滚
+func TransitionDefect(defectID uint, action DefectAction, actor OrderActor, reason string, apply func(tx *gorm.DB, defect *models.Defect) error) (*models.Defect, error) {
+	var defect models.Defect
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		defect, err = transitionDefect(tx, defectID, action, actor, reason, apply)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &defect, nil
+}
+
+// transitionDefect 在调用方的事务中执行整改单状态迁移
+func transitionDefect(tx *gorm.DB, defectID uint, action DefectAction, actor OrderActor, reason string, apply func(tx *gorm.DB, defect *models.Defect) error) (models.Defect, error) {
+	transition, ok := defectTransitions[action]
+	if !ok {
+		return models.Defect{}, fmt.Errorf("未知的整改单操作: %s", action)
+	}
+
+	var defect models.Defect
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&defect, defectID).Error; err != nil {
+		return defect, err
+	}
+
+	if !slices.Contains(transition.from, defect.Status) {
+		return defect, &DefectTransitionError{Action: action, From: defect.Status}
+	}
+	roles, err := defectRoles(tx, defect, actor)
+	if err != nil {
+		return defect, err
+	}
+	if !slices.ContainsFunc(roles, func(role DefectRole) bool { return slices.Contains(transition.roles, role) }) {
+		return defect, ErrDefectActorNotAllowed
+	}
+
+	defect.Status = transition.to
+	if reason != "" {
+		defect.StatusReason = reason
+	}
+	if apply != nil {
+		if err := apply(tx, &defect); err != nil {
+			return defect, err
+		}
+	}
+	return defect, tx.Omit(clause.Associations).Save(&defect).Error
+}
+
+// StartDefectRepair 开始整改
+func StartDefectRepair(defectID uint, actor OrderActor) (*models.Defect, error) {
+	return TransitionDefect(defectID, DefectActionStart, actor, "", nil)
+}
+
+// ResolveDefect 整改负责人提交整改结果，同时为整改单对应的点位生成复检工单，复检合格后整改单自动关闭
+func ResolveDefect(defectID uint, actor OrderActor, note string) (*models.Defect, error) {
+	return TransitionDefect(defectID, DefectActionResolve, actor, "", func(tx *gorm.DB, defect *models.Defect) error {
+		now := time.Now()
+		defect.RepairNote = note
+		defect.RepairedAt = &now
+
+		var source models.InspectionOrder
+		if err := tx.Preload("Plan").First(&source, defect.OrderID).Error; err != nil {
+			return err
+		}
+		order, err := buildOrder(tx, OrderRequest{Plan: source.Plan, CreatedBy: actor.UserID, Defect: defect}, nil, now.Truncate(time.Second))
+		if err != nil {
+			return err
+		}
+		defect.VerificationOrderID = &order.ID
+		return nil
+	})
+}
+
+// settleVerification 复检工单结单或取消时处理对应的整改单：
+// 复检合格则关闭整改单，复检不合格或复检工单被取消则退回继续整改；巡检项的整改单按该巡检项的结果判断
+func settleVerification(tx *gorm.DB, order models.InspectionOrder) error {
+	var defect models.Defect
+	if err := tx.First(&defect, *order.DefectID).Error; err != nil {
+		return err
+	}
+	// 整改单已重新提交整改时以最新的复检工单为准
+	if defect.Status != models.DefectStatusPendingVerification || defect.VerificationOrderID == nil || *defect.VerificationOrderID != order.ID {
+		return nil
+	}
+
+	action, reason := DefectActionReject, "复检工单已取消"
+	if order.Status == models.OrderStatusCompleted {
+		var check models.InspectionPointCheck
+		if err := tx.Where("order_id = ? AND point_id = ?", order.ID, defect.PointID).First(&check).Error; err != nil {
+			return err
+		}
+		// 巡检项的整改单只看该巡检项的复检结果，不受同一点位其他巡检项影响
+		status := check.Status
+		if defect.ItemID != nil {
+			var result models.InspectionItemResult
+			err := tx.Where("check_id = ? AND item_id = ?", check.ID, *defect.ItemID).First(&result).Error
+			switch {
+			case err == nil:
+				status = result.Status
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				status = models.CheckStatusPending
+			default:
+				return err
+			}
+		}
+		action, reason = DefectActionVerify, "复检合格"
+		if status != models.CheckStatusPassed {
+			action, reason = DefectActionReject, "复检不合格"
+		}
+	}
+
+	_, err := transitionDefect(tx, defect.ID, action, SystemActor, reason, func(tx *gorm.DB, defect *models.Defect) error {
+		if action == DefectActionVerify {
+			now := time.Now()
+			defect.ClosedAt = &now
+		} else {
+			defect.RepairedAt = nil
+		}
+		return nil
+	})
+	return err
+}
+
+// CloseDefect 关闭未整改的整改单
+func CloseDefect(defectID uint, actor OrderActor, reason string) (*models.Defect, error) {
+	return TransitionDefect(defectID, DefectActionClose, actor, reason, func(tx *gorm.DB, defect *models.Defect) error {
+		now := time.Now()
+		defect.ClosedByID = &actor.UserID
+		defect.ClosedAt = &now
+		return nil
+	})
+}
+
+// DefectUpdate 可修改的整改单信息，为空的字段不修改
+type DefectUpdate struct {
+	Title         *string                `json:"title" binding:"omitempty,max=255"`
+	Description   *string                `json:"description"`
+	Severity      *models.DefectSeverity `json:"severity"`
+	ResponsibleID *uint                  `json:"responsible_id"`
+	DueAt         *time.Time             `json:"due_at"`
+}
+
+// UpdateDefect 修改未关闭的整改单的严重程度、负责人、整改期限等信息，仅管理人可以修改
+func UpdateDefect(defectID uint, actor OrderActor, input DefectUpdate) (*models.Defect, error) {
+	var defect models.Defect
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&defect, defectID).Error; err != nil {
+			return err
+		}
+		if defect.Status == models.DefectStatusClosed {
+			return ErrDefectClosed
+		}
+		roles, err := defectRoles(tx, defect, actor)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(roles, DefectRoleManager) {
+			return ErrDefectActorNotAllowed
+		}
+
+		if input.Title != nil {
+			defect.Title = *input.Title
+		}
+		if input.Description != nil {
+			defect.Description = *input.Description
+		}
+		if input.Severity != nil {
+			if !ValidSeverity(*input.Severity) {
+				return ErrInvalidSeverity
+			}
+			defect.Severity = *input.Severity
+		}
+		if input.ResponsibleID != nil {
+			var responsible models.User
+			if err := tx.First(&responsible, *input.ResponsibleID).Error; err != nil {
+				return ErrAssigneeNotFound
+			}
+			defect.ResponsibleID = &responsible.ID
+		}
+		if input.DueAt != nil {
+			defect.DueAt = input.DueAt
+		}
+		return tx.Omit(clause.Associations).Save(&defect).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &defect, nil
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"testing"
+	"time"
+)
+
+// pointDefects 返回巡检点的所有整改单
+func pointDefects(t *testing.T, pointID uint) []models.Defect {
+	t.Helper()
+	var defects []models.Defect
+	if err := config.DB.Where("point_id = ?", pointID).Order("id").Find(&defects).Error; err != nil {
+		t.Fatal(err)
+	}
+	return defects
+}
+
+// assertDue 检查整改期限为当前时间加 want
+func assertDue(t *testing.T, defect models.Defect, want time.Duration) {
+	t.Helper()
+	if defect.DueAt == nil {
+		t.Errorf("defect %d has no due date", defect.ID)
+		return
+	}
+	if diff := time.Until(*defect.DueAt) - want; diff > time.Minute || diff < -time.Minute {
+		t.Errorf("defect %d due in %v, want %v", defect.ID, time.Until(*defect.DueAt), want)
+	}
+}
+
+func TestFailedCheckOpensDefect(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 2)
+	order := f.startedOrder(t)
+	failed := CheckInput{Status: models.CheckStatusFailed, Comment: "阀门渗漏"}
+
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckPoint(order.ID, f.points[1].ID, f.assigneeActor(), CheckInput{Status: models.CheckStatusPassed}); err != nil {
+		t.Fatal(err)
+	}
+
+	defects := pointDefects(t, f.points[0].ID)
+	if len(defects) != 1 {
+		t.Fatalf("defects = %d, want 1", len(defects))
+	}
+	defect := defects[0]
+	if defect.Status != models.DefectStatusOpen || defect.Severity != models.DefectSeverityMedium || defect.ItemID != nil {
+		t.Errorf("defect status = %s, severity = %s, item = %v, want open, medium, nil", defect.Status, defect.Severity, defect.ItemID)
+	}
+	if defect.OrderID != order.ID || defect.ProjectID != f.project.ID || defect.Description != "阀门渗漏" {
+		t.Errorf("defect order = %d, project = %d, description = %q", defect.OrderID, defect.ProjectID, defect.Description)
+	}
+	// 项目没有负责人时由计划派单人负责整改
+	if defect.ResponsibleID == nil || *defect.ResponsibleID != f.assigner.ID || defect.ReportedByID == nil || *defect.ReportedByID != f.assignee.ID {
+		t.Errorf("defect responsible = %v, reporter = %v", defect.ResponsibleID, defect.ReportedByID)
+	}
+	assertDue(t, defect, 7*24*time.Hour)
+	if got := pointDefects(t, f.points[1].ID); len(got) != 0 {
+		t.Errorf("passed point has %d defects", len(got))
+	}
+
+	// 同一工单重新提交和之后的工单再次不合格，都不重复生成
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), failed); err != nil {
+		t.Fatal(err)
+	}
+	next := f.startedOrder(t)
+	if _, err := CheckPoint(next.ID, f.points[0].ID, f.assigneeActor(), failed); err != nil {
+		t.Fatal(err)
+	}
+	if got := pointDefects(t, f.points[0].ID); len(got) != 1 {
+		t.Errorf("defects after failing again = %d, want 1", len(got))
+	}
+}
+
+func TestFailedItemsOpenDefects(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1,
+		models.InspectionItem{Title: "压力", ExecutionMethod: "读表", Type: models.ItemTypeNumeric, MinValue: float(0.2), MaxValue: float(0.6)},
+		models.InspectionItem{Title: "外观", ExecutionMethod: "目视", Type: models.ItemTypeBoolean},
+	)
+	pressure, appearance := f.points[0].Items[0].ID, f.points[0].Items[1].ID
+	order := f.startedOrder(t)
+	input := CheckInput{Items: []ItemResultInput{
+		{ItemID: pressure, Value: "0.9"},
+		{ItemID: appearance, Status: models.CheckStatusFailed, Comment: "锈蚀"},
+	}}
+
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), input); err != nil {
+		t.Fatal(err)
+	}
+	defects := pointDefects(t, f.points[0].ID)
+	if len(defects) != 2 {
+		t.Fatalf("defects = %d, want one per failed item", len(defects))
+	}
+	tests := []struct {
+		itemID   uint
+		severity models.DefectSeverity
+		due      time.Duration
+	}{
+		{pressure, models.DefectSeverityHigh, 72 * time.Hour},
+		{appearance, models.DefectSeverityMedium, 7 * 24 * time.Hour},
+	}
+	for i, tt := range tests {
+		defect := defects[i]
+		if defect.ItemID == nil || *defect.ItemID != tt.itemID || defect.Severity != tt.severity {
+			t.Errorf("defect %d item = %v, severity = %s, want %d and %s", i, defect.ItemID, defect.Severity, tt.itemID, tt.severity)
+		}
+		assertDue(t, defect, tt.due)
+	}
+
+	// 复查仍不合格不重复生成；只有外观不合格时压力的整改单保持不变
+	input.Items[0].Value = "0.4"
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), input); err != nil {
+		t.Fatal(err)
+	}
+	if got := pointDefects(t, f.points[0].ID); len(got) != 2 {
+		t.Errorf("defects after re-check = %d, want 2", len(got))
+	}
+}
+
+func TestTransitionDefectRoles(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order := f.startedOrder(t)
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), CheckInput{Status: models.CheckStatusFailed}); err != nil {
+		t.Fatal(err)
+	}
+	defect := pointDefects(t, f.points[0].ID)[0]
+
+	// 巡检员既不是整改负责人也不是管理人
+	if _, err := StartDefectRepair(defect.ID, f.assigneeActor()); !errors.Is(err, ErrDefectActorNotAllowed) {
+		t.Errorf("start by inspector: error = %v, want ErrDefectActorNotAllowed", err)
+	}
+	var transitionErr *DefectTransitionError
+	if _, err := ResolveDefect(defect.ID, f.assignerActor(), ""); !errors.As(err, &transitionErr) {
+		t.Errorf("resolve an open defect: error = %v, want *DefectTransitionError", err)
+	}
+	if _, err := StartDefectRepair(defect.ID, f.assignerActor()); err != nil {
+		t.Fatalf("start by responsible: %v", err)
+	}
+	// 复检结论只能由复检工单给出
+	if _, err := TransitionDefect(defect.ID, DefectActionVerify, f.assignerActor(), "", nil); err == nil {
+		t.Error("user verified a defect directly")
+	}
+	if got := reload[models.Defect](t, defect.ID); got.Status != models.DefectStatusInRepair {
+		t.Errorf("status = %s, want in_repair", got.Status)
+	}
+}
+
+// resolveForVerification 整改负责人开始整改并提交，返回复检工单
+func resolveForVerification(t *testing.T, f *testFixture, defectID uint) *models.InspectionOrder {
+	t.Helper()
+	if _, err := StartDefectRepair(defectID, f.assignerActor()); err != nil {
+		t.Fatal(err)
+	}
+	defect, err := ResolveDefect(defectID, f.assignerActor(), "已更换密封圈")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defect.Status != models.DefectStatusPendingVerification || defect.VerificationOrderID == nil {
+		t.Fatalf("resolved defect status = %s, verification order = %v", defect.Status, defect.VerificationOrderID)
+	}
+	order, err := StartOrder(*defect.VerificationOrderID, f.assigneeActor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestVerificationOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		status models.CheckStatus
+		want   models.DefectStatus
+	}{
+		{"passed closes the defect", models.CheckStatusPassed, models.DefectStatusClosed},
+		{"failed returns it to repair", models.CheckStatusFailed, models.DefectStatusInRepair},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			f := newTestFixture(t, db, 3)
+			if err := db.Model(&f.plan).Update("skip_review", true).Error; err != nil {
+				t.Fatal(err)
+			}
+			order := f.startedOrder(t)
+			if _, err := CheckPoint(order.ID, f.points[1].ID, f.assigneeActor(), CheckInput{Status: models.CheckStatusFailed}); err != nil {
+				t.Fatal(err)
+			}
+			defect := pointDefects(t, f.points[1].ID)[0]
+
+			verification := resolveForVerification(t, f, defect.ID)
+			var checks []models.InspectionPointCheck
+			if err := db.Where("order_id = ?", verification.ID).Find(&checks).Error; err != nil {
+				t.Fatal(err)
+			}
+			if len(checks) != 1 || checks[0].PointID != f.points[1].ID || verification.TotalChecks != 1 {
+				t.Fatalf("verification order has %d checks (total %d), want only point %d", len(checks), verification.TotalChecks, f.points[1].ID)
+			}
+			if verification.DefectID == nil || *verification.DefectID != defect.ID || *verification.RouteVersionID != *order.RouteVersionID {
+				t.Errorf("verification order defect = %v, route version = %v", verification.DefectID, verification.RouteVersionID)
+			}
+
+			if _, err := CheckPoint(verification.ID, f.points[1].ID, f.assigneeActor(), CheckInput{Status: tt.status}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := CompleteOrder(verification.ID, f.assigneeActor(), ""); err != nil {
+				t.Fatal(err)
+			}
+
+			got := reload[models.Defect](t, defect.ID)
+			if got.Status != tt.want {
+				t.Errorf("defect status = %s, want %s", got.Status, tt.want)
+			}
+			if (got.ClosedAt != nil) != (tt.want == models.DefectStatusClosed) || (got.RepairedAt != nil) != (tt.want == models.DefectStatusClosed) {
+				t.Errorf("defect closed at = %v, repaired at = %v", got.ClosedAt, got.RepairedAt)
+			}
+			// 复检不合格退回原整改单，不另外生成
+			if defects := pointDefects(t, f.points[1].ID); len(defects) != 1 {
+				t.Errorf("defects on the point = %d, want 1", len(defects))
+			}
+		})
+	}
+}
+
+func TestVerificationOrderItemDefect(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1,
+		models.InspectionItem{Title: "压力", ExecutionMethod: "读表", Type: models.ItemTypeNumeric, MaxValue: float(0.6)},
+		models.InspectionItem{Title: "外观", ExecutionMethod: "目视", Type: models.ItemTypeBoolean},
+	)
+	if err := db.Model(&f.plan).Update("skip_review", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	pressure, appearance := f.points[0].Items[0].ID, f.points[0].Items[1].ID
+	order := f.startedOrder(t)
+	if _, err := CheckPoint(order.ID, f.points[0].ID, f.assigneeActor(), CheckInput{Items: []ItemResultInput{
+		{ItemID: pressure, Value: "0.9"},
+		{ItemID: appearance, Status: models.CheckStatusPassed},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	defect := pointDefects(t, f.points[0].ID)[0]
+
+	// 复检时压力合格、外观不合格：压力的整改单关闭，外观另行生成整改单
+	verification := resolveForVerification(t, f, defect.ID)
+	if _, err := CheckPoint(verification.ID, f.points[0].ID, f.assigneeActor(), CheckInput{Items: []ItemResultInput{
+		{ItemID: pressure, Value: "0.5"},
+		{ItemID: appearance, Status: models.CheckStatusFailed},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompleteOrder(verification.ID, f.assigneeActor(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reload[models.Defect](t, defect.ID); got.Status != models.DefectStatusClosed {
+		t.Errorf("pressure defect status = %s, want closed", got.Status)
+	}
+	defects := pointDefects(t, f.points[0].ID)
+	if len(defects) != 2 || defects[1].ItemID == nil || *defects[1].ItemID != appearance || defects[1].OrderID != verification.ID {
+		t.Errorf("defects after verification = %+v, want a new one for the appearance item", defects)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/models"
+	"math"
+
+	"github.com/spf13/viper"
+)
+
+// earthRadiusMeters 地球平均半径（米）
+const earthRadiusMeters = 6371000
+
+var (
+	// ErrLocationRequired 巡检点设置了地理围栏，确认时必须上报位置
+	ErrLocationRequired = fmt.Errorf("%w：请开启定位后再确认巡检点", ErrInvalidCheckInput)
+	// ErrOutsideGeofence 确认位置超出巡检点的地理围栏
+	ErrOutsideGeofence = fmt.Errorf("%w：当前位置距离巡检点过远", ErrInvalidCheckInput)
+)
+
+// Coordinate 经纬度坐标（WGS84）
+type Coordinate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DistanceMeters 按球面距离公式计算两点之间的距离（米）
+func DistanceMeters(a, b Coordinate) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// PointCoordinate 返回巡检点的坐标，未设置时返回 false
+func PointCoordinate(point models.InspectionPoint) (Coordinate, bool) {
+	if point.Latitude == nil || point.Longitude == nil {
+		return Coordinate{}, false
+	}
+	return Coordinate{Latitude: *point.Latitude, Longitude: *point.Longitude}, true
+}
+
+// ValidatePointLocation 校验巡检点的坐标和地理围栏配置
+func ValidatePointLocation(point models.InspectionPoint) error {
+	if (point.Latitude == nil) != (point.Longitude == nil) {
+		return errors.New("纬度和经度必须同时填写")
+	}
+	if point.Latitude != nil && (*point.Latitude < -90 || *point.Latitude > 90 || *point.Longitude < -180 || *point.Longitude > 180) {
+		return errors.New("经纬度超出范围")
+	}
+	if point.GeofenceRadius < 0 {
+		return errors.New("地理围栏半径不能为负数")
+	}
+	if point.GeofenceRadius > 0 && point.Latitude == nil {
+		return errors.New("设置地理围栏需要先填写巡检点的经纬度")
+	}
+	switch point.GeofenceMode {
+	case "", models.GeofenceModeFlag, models.GeofenceModeReject:
+	default:
+		return errors.New("无效的地理围栏模式")
+	}
+	return nil
+}
+
+// geofenceMode 返回巡检点生效的地理围栏模式
+func geofenceMode(point models.InspectionPoint) models.GeofenceMode {
+	if point.GeofenceMode != "" {
+		return point.GeofenceMode
+	}
+	if mode := models.GeofenceMode(viper.GetString("inspection.geofence.mode")); mode == models.GeofenceModeReject {
+		return mode
+	}
+	return models.GeofenceModeFlag
+}
+
+// checkGeofence 记录确认位置与巡检点的距离，并按巡检点的地理围栏标记或拒绝超出范围的确认。
+// 计算时扣除上报的 GPS 精度，避免定位漂移造成误判；精度由客户端上报，扣除的距离最多为围栏半径
+func checkGeofence(point models.InspectionPoint, check *models.InspectionPointCheck, latitude, longitude, accuracy *float64) error {
+	check.Latitude, check.Longitude, check.Accuracy = latitude, longitude, accuracy
+	check.Distance = nil
+	check.OutsideGeofence = false
+
+	target, ok := PointCoordinate(point)
+	if latitude == nil || longitude == nil {
+		if ok && point.GeofenceRadius > 0 {
+			if geofenceMode(point) == models.GeofenceModeReject {
+				return ErrLocationRequired
+			}
+			check.OutsideGeofence = true
+		}
+		return nil
+	}
+	if !(*latitude >= -90 && *latitude <= 90 && *longitude >= -180 && *longitude <= 180) {
+		return fmt.Errorf("%w：经纬度超出范围", ErrInvalidCheckInput)
+	}
+	if accuracy != nil && !(*accuracy >= 0 && *accuracy <= math.MaxFloat64) {
+		return fmt.Errorf("%w：GPS 精度不合法", ErrInvalidCheckInput)
+	}
+	if !ok {
+		return nil
+	}
+
+	distance := DistanceMeters(Coordinate{Latitude: *latitude, Longitude: *longitude}, target)
+	check.Distance = &distance
+	if point.GeofenceRadius <= 0 {
+		return nil
+	}
+	tolerance := 0.0
+	if accuracy != nil && *accuracy > 0 {
+		tolerance = min(*accuracy, point.GeofenceRadius)
+	}
+	if distance-tolerance > point.GeofenceRadius {
+		if geofenceMode(point) == models.GeofenceModeReject {
+			return ErrOutsideGeofence
+		}
+		check.OutsideGeofence = true
+	}
+	return nil
+}
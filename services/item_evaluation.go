@@ -0,0 +1,92 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-inspect/models"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ValidateItemDefinition 校验巡检项类型及其上下限、选项配置
+func ValidateItemDefinition(item models.InspectionItem) error {
+	switch item.Type {
+	case "", models.ItemTypeBoolean, models.ItemTypeText, models.ItemTypePhoto:
+	case models.ItemTypeNumeric:
+		if item.MinValue != nil && item.MaxValue != nil && *item.MinValue > *item.MaxValue {
+			return fmt.Errorf("下限不能大于上限")
+		}
+	case models.ItemTypeSingleChoice, models.ItemTypeMultiChoice:
+		if len(item.Options) == 0 {
+			return fmt.Errorf("选择类巡检项必须设置选项")
+		}
+	default:
+		return fmt.Errorf("无效的巡检项类型")
+	}
+	return nil
+}
+
+// EvaluateItemResult 按巡检项类型校验填写的值并得出该项结果：
+// 合格/不合格类必须明确填写状态；其他类型未填写状态时视为合格，数值读数超出上下限时强制为不合格；
+// 拍照类必须先上传照片附件，hasPhoto 表示是否已上传
+func EvaluateItemResult(item models.InspectionItem, answer ItemResultInput, hasPhoto bool) (models.InspectionItemResult, error) {
+	result := models.InspectionItemResult{
+		ItemID:  item.ID,
+		Status:  answer.Status,
+		Value:   strings.TrimSpace(answer.Value),
+		Comment: answer.Comment,
+	}
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w：巡检项「%s」%s", ErrInvalidCheckInput, item.Title, fmt.Sprintf(format, args...))
+	}
+
+	switch item.Type {
+	case models.ItemTypeBoolean, "":
+		if !isFinalCheckStatus(answer.Status) {
+			return result, invalid("的状态必须为 passed 或 failed")
+		}
+		return result, nil
+	case models.ItemTypeNumeric:
+		reading, err := strconv.ParseFloat(result.Value, 64)
+		if err != nil || math.IsNaN(reading) || math.IsInf(reading, 0) {
+			return result, invalid("的读数必须为数字")
+		}
+		result.Reading = &reading
+		result.OutOfRange = (item.MinValue != nil && reading < *item.MinValue) || (item.MaxValue != nil && reading > *item.MaxValue)
+	case models.ItemTypeSingleChoice:
+		if !slices.Contains(item.Options, result.Value) {
+			return result, invalid("的选项无效")
+		}
+	case models.ItemTypeMultiChoice:
+		if len(answer.Values) == 0 {
+			return result, invalid("至少选择一项")
+		}
+		for _, value := range answer.Values {
+			if !slices.Contains(item.Options, value) {
+				return result, invalid("的选项「%s」无效", value)
+			}
+		}
+		values, _ := json.Marshal(answer.Values)
+		result.Value = string(values)
+	case models.ItemTypeText:
+		if result.Value == "" {
+			return result, invalid("必须填写")
+		}
+	case models.ItemTypePhoto:
+		if !hasPhoto {
+			return result, invalid("必须拍照")
+		}
+	}
+
+	switch {
+	case result.OutOfRange:
+		result.Status = models.CheckStatusFailed
+	case answer.Status == "":
+		result.Status = models.CheckStatusPassed
+	case !isFinalCheckStatus(answer.Status):
+		return result, invalid("的状态必须为 passed 或 failed")
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/models"
+	"testing"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestValidateItemDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    models.InspectionItem
+		wantErr bool
+	}{
+		{"default type", models.InspectionItem{}, false},
+		{"boolean", models.InspectionItem{Type: models.ItemTypeBoolean}, false},
+		{"numeric open range", models.InspectionItem{Type: models.ItemTypeNumeric, MinValue: float(0)}, false},
+		{"numeric equal bounds", models.InspectionItem{Type: models.ItemTypeNumeric, MinValue: float(5), MaxValue: float(5)}, false},
+		{"numeric min above max", models.InspectionItem{Type: models.ItemTypeNumeric, MinValue: float(10), MaxValue: float(5)}, true},
+		{"single choice", models.InspectionItem{Type: models.ItemTypeSingleChoice, Options: []string{"正常", "异常"}}, false},
+		{"single choice without options", models.InspectionItem{Type: models.ItemTypeSingleChoice}, true},
+		{"multi choice without options", models.InspectionItem{Type: models.ItemTypeMultiChoice}, true},
+		{"text", models.InspectionItem{Type: models.ItemTypeText}, false},
+		{"photo", models.InspectionItem{Type: models.ItemTypePhoto}, false},
+		{"unknown type", models.InspectionItem{Type: "video"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateItemDefinition(tt.item); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEvaluateItemResult(t *testing.T) {
+	boolean := models.InspectionItem{Type: models.ItemTypeBoolean}
+	numeric := models.InspectionItem{Type: models.ItemTypeNumeric, MinValue: float(10), MaxValue: float(20)}
+	single := models.InspectionItem{Type: models.ItemTypeSingleChoice, Options: []string{"正常", "异常"}}
+	multi := models.InspectionItem{Type: models.ItemTypeMultiChoice, Options: []string{"漏油", "异响", "过热"}}
+	text := models.InspectionItem{Type: models.ItemTypeText}
+	photo := models.InspectionItem{Type: models.ItemTypePhoto}
+
+	tests := []struct {
+		name       string
+		item       models.InspectionItem
+		answer     ItemResultInput
+		hasPhoto   bool
+		wantErr    bool
+		wantStatus models.CheckStatus
+		wantValue  string
+		outOfRange bool
+	}{
+		{name: "boolean passed", item: boolean, answer: ItemResultInput{Status: models.CheckStatusPassed}, wantStatus: models.CheckStatusPassed},
+		{name: "boolean failed", item: boolean, answer: ItemResultInput{Status: models.CheckStatusFailed}, wantStatus: models.CheckStatusFailed},
+		{name: "boolean without status", item: boolean, answer: ItemResultInput{}, wantErr: true},
+		{name: "boolean pending", item: boolean, answer: ItemResultInput{Status: models.CheckStatusPending}, wantErr: true},
+		{name: "default type needs status", item: models.InspectionItem{}, answer: ItemResultInput{}, wantErr: true},
+
+		{name: "numeric in range", item: numeric, answer: ItemResultInput{Value: " 15.5 "}, wantStatus: models.CheckStatusPassed, wantValue: "15.5"},
+		{name: "numeric on lower bound", item: numeric, answer: ItemResultInput{Value: "10"}, wantStatus: models.CheckStatusPassed, wantValue: "10"},
+		{name: "numeric below range", item: numeric, answer: ItemResultInput{Value: "9.9"}, wantStatus: models.CheckStatusFailed, wantValue: "9.9", outOfRange: true},
+		{name: "numeric above range overrides passed", item: numeric, answer: ItemResultInput{Value: "21", Status: models.CheckStatusPassed}, wantStatus: models.CheckStatusFailed, wantValue: "21", outOfRange: true},
+		{name: "numeric in range marked failed", item: numeric, answer: ItemResultInput{Value: "12", Status: models.CheckStatusFailed}, wantStatus: models.CheckStatusFailed, wantValue: "12"},
+		{name: "numeric not a number", item: numeric, answer: ItemResultInput{Value: "abc"}, wantErr: true},
+		{name: "numeric empty", item: numeric, answer: ItemResultInput{}, wantErr: true},
+		{name: "numeric NaN", item: numeric, answer: ItemResultInput{Value: "NaN"}, wantErr: true},
+		{name: "numeric Inf", item: numeric, answer: ItemResultInput{Value: "+Inf"}, wantErr: true},
+		{name: "numeric invalid status", item: numeric, answer: ItemResultInput{Value: "15", Status: "ok"}, wantErr: true},
+
+		{name: "single choice", item: single, answer: ItemResultInput{Value: "异常"}, wantStatus: models.CheckStatusPassed, wantValue: "异常"},
+		{name: "single choice invalid option", item: single, answer: ItemResultInput{Value: "未知"}, wantErr: true},
+
+		{name: "multi choice", item: multi, answer: ItemResultInput{Values: []string{"漏油", "过热"}, Status: models.CheckStatusFailed}, wantStatus: models.CheckStatusFailed, wantValue: `["漏油","过热"]`},
+		{name: "multi choice empty", item: multi, answer: ItemResultInput{}, wantErr: true},
+		{name: "multi choice invalid option", item: multi, answer: ItemResultInput{Values: []string{"漏油", "未知"}}, wantErr: true},
+
+		{name: "text", item: text, answer: ItemResultInput{Value: "运行正常"}, wantStatus: models.CheckStatusPassed, wantValue: "运行正常"},
+		{name: "text blank", item: text, answer: ItemResultInput{Value: "   "}, wantErr: true},
+
+		{name: "photo uploaded", item: photo, hasPhoto: true, wantStatus: models.CheckStatusPassed},
+		{name: "photo missing", item: photo, wantErr: true},
+	}
+	for _, tt := range tests {
+		result, err := EvaluateItemResult(tt.item, tt.answer, tt.hasPhoto)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCheckInput) {
+				t.Errorf("%s: error = %v, want ErrInvalidCheckInput", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if result.Status != tt.wantStatus || result.Value != tt.wantValue || result.OutOfRange != tt.outOfRange {
+			t.Errorf("%s: got status=%s value=%q outOfRange=%v, want status=%s value=%q outOfRange=%v",
+				tt.name, result.Status, result.Value, result.OutOfRange, tt.wantStatus, tt.wantValue, tt.outOfRange)
+		}
+	}
+}
+
+func TestEvaluateItemResultReading(t *testing.T) {
+	item := models.InspectionItem{Type: models.ItemTypeNumeric}
+	result, err := EvaluateItemResult(item, ItemResultInput{Value: "-3.25"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reading == nil || *result.Reading != -3.25 {
+		t.Errorf("Reading = %v, want -3.25", result.Reading)
+	}
+	if result.OutOfRange {
+		t.Error("OutOfRange = true without bounds")
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"go-inspect/models"
+
+	"gorm.io/gorm"
+)
+
+// 状态迁移之外写入工单时间线的动作
+const (
+	OrderActionCreate   OrderAction = "create"
+	OrderActionEscalate OrderAction = "escalate"
+)
+
+// recordOrderEvent 在事务中追加一条工单事件，before 为变更前的工单，order 为变更后的工单
+func recordOrderEvent(tx *gorm.DB, action OrderAction, actor OrderActor, before, order models.InspectionOrder, reason string) error {
+	event := models.OrderEvent{
+		OrderID:           order.ID,
+		Action:            string(action),
+		FromStatus:        before.Status,
+		ToStatus:          order.Status,
+		FromAssigneeID:    before.AssigneeID,
+		ToAssigneeID:      order.AssigneeID,
+		FromEscalation:    before.EscalationLevel,
+		ToEscalation:      order.EscalationLevel,
+		FromEscalatedToID: before.EscalatedToID,
+		ToEscalatedToID:   order.EscalatedToID,
+		Reason:            reason,
+	}
+	if actor.UserID != 0 {
+		event.ActorID = &actor.UserID
+	}
+	return tx.Create(&event).Error
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrOrderAlreadyGenerated 同一计划的同一触发时刻已生成过工单
+	ErrOrderAlreadyGenerated = errors.New("该计划本次触发的巡检工单已生成")
+	// ErrOccurrenceSkipped 触发时刻落在停检日且按计划规则跳过
+	ErrOccurrenceSkipped = errors.New("触发时刻为停检日，已按计划规则跳过")
+	// ErrPlanNotActive 计划未处于启用状态
+	ErrPlanNotActive = errors.New("巡检计划未处于启用状态")
+	// ErrPlanTriggeredRecently 计划在手动触发的时间窗口内已手动触发过
+	ErrPlanTriggeredRecently = errors.New("巡检计划刚刚已手动触发，请勿重复触发")
+)
+
+// OrderRequest 生成巡检工单的参数
+type OrderRequest struct {
+	Plan       models.InspectionPlan
+	Occurrence time.Time      // 计划触发时刻，与计划ID共同唯一
+	Scheduled  bool           // 由调度器触发：按停检日规则调整执行时刻，并推进计划的 LastOccurrence、累计 OccurrenceCount
+	CreatedBy  uint           // 手动触发的用户，调度触发时为 0
+	Manual     bool           // 手动触发，不占用计划的触发时刻
+	Defect     *models.Defect // 复检的整改单，复检工单只巡检整改单对应的点位
+}
+
+// TriggerPlanManually 手动触发计划立即生成工单。在事务中锁定计划后检查状态和 inspection.manual_trigger_window
+// 时间窗口，并发触发时只有一个能生成；窗口只与上一次手动触发比较，调度生成的工单不影响手动触发。
+// 手动工单不占用调度的触发时刻，也不改变调度起点，不影响调度器按时生成
+func TriggerPlanManually(planID, createdBy uint) (*models.InspectionOrder, error) {
+	var order models.InspectionOrder
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var plan models.InspectionPlan
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&plan, planID).Error; err != nil {
+			return err
+		}
+		if plan.Status != models.PlanStatusActive {
+			return ErrPlanNotActive
+		}
+
+		now := time.Now()
+		window := viper.GetDuration("inspection.manual_trigger_window")
+		if window > 0 && plan.LastManualAt != nil && now.Sub(*plan.LastManualAt) < window {
+			return ErrPlanTriggeredRecently
+		}
+
+		var err error
+		if order, err = buildOrder(tx, OrderRequest{Plan: plan, CreatedBy: createdBy, Manual: true}, nil, now.Truncate(time.Second)); err != nil {
+			return err
+		}
+		return tx.Model(&models.InspectionPlan{}).Where("id = ?", plan.ID).Update("last_manual_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GenerateInspectionOrder 根据巡检计划生成巡检工单及其巡检点确认记录，
+// 工单、确认记录和计划的触发记录在同一事务中写入；
+// (plan_id, occurrence) 唯一，重复生成时返回已存在的工单和 ErrOrderAlreadyGenerated
+func GenerateInspectionOrder(req OrderRequest) (*models.InspectionOrder, error) {
+	plan := req.Plan
+	occurrence := req.Occurrence.Truncate(time.Second)
+
+	// 手动触发即刻执行，调度触发按项目日历调整执行时刻
+	scheduledAt := occurrence
+	if req.Scheduled {
+		cal, err := ProjectCalendar(plan.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if scheduledAt, ok = ScheduledTime(plan, occurrence, cal); !ok {
+			return nil, ErrOccurrenceSkipped
+		}
+	}
+
+	var order models.InspectionOrder
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if existing, err := findOrderByOccurrence(tx, plan.ID, occurrence); err == nil {
+			order = *existing
+			return ErrOrderAlreadyGenerated
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var err error
+		if order, err = buildOrder(tx, req, &occurrence, scheduledAt); err != nil {
+			return err
+		}
+
+		if !req.Scheduled {
+			return nil
+		}
+		return tx.Model(&models.InspectionPlan{}).Where("id = ?", plan.ID).Updates(map[string]interface{}{
+			"last_occurrence":  occurrence,
+			"occurrence_count": gorm.Expr("occurrence_count + 1"),
+		}).Error
+	})
+
+	if errors.Is(err, ErrOrderAlreadyGenerated) {
+		return &order, err
+	}
+	if err != nil {
+		// 并发生成时唯一索引冲突，以已存在的工单为准
+		if existing, findErr := findOrderByOccurrence(config.DB, plan.ID, occurrence); findErr == nil {
+			return existing, ErrOrderAlreadyGenerated
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+func findOrderByOccurrence(db *gorm.DB, planID uint, occurrence time.Time) (*models.InspectionOrder, error) {
+	var order models.InspectionOrder
+	if err := db.Where("plan_id = ? AND occurrence = ?", planID, occurrence).First(&order).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// buildOrder 在事务中创建工单、按计划的分配策略指派执行人，并创建各巡检点的确认记录
+func buildOrder(tx *gorm.DB, req OrderRequest, occurrence *time.Time, scheduledAt time.Time) (models.InspectionOrder, error) {
+	plan := req.Plan
+	var order models.InspectionOrder
+
+	// 按路线顺序获取巡检点并固定到路线的当前版本；复检工单只巡检整改单对应的点位，沿用原工单的路线版本
+	var pointIDs []uint
+	if req.Defect != nil {
+		var source models.InspectionOrder
+		if err := tx.Select("id", "route_version_id").First(&source, req.Defect.OrderID).Error; err != nil {
+			return order, err
+		}
+		pointIDs = []uint{req.Defect.PointID}
+		order.DefectID = &req.Defect.ID
+		order.RouteVersionID = source.RouteVersionID
+	} else {
+		version, err := pinRouteVersion(tx, plan.RouteID)
+		if err != nil {
+			return order, err
+		}
+		for _, point := range version.Points {
+			pointIDs = append(pointIDs, point.PointID)
+		}
+		order.RouteVersionID = &version.ID
+	}
+
+	order.PlanID = plan.ID
+	order.Status = models.OrderStatusPending
+	order.Occurrence = occurrence
+	order.Manual = req.Manual
+	order.ScheduledAt = &scheduledAt
+	order.DueAt = DueTime(plan, scheduledAt)
+	order.TotalChecks = len(pointIDs)
+	order.Version = 1
+	if plan.AssignerID != 0 {
+		order.AssignerID = &plan.AssignerID
+	}
+
+	// 按计划的分配策略选择执行人，未分配的工单等待认领
+	assigneeID, err := pickAssignee(tx, plan)
+	if err != nil {
+		return order, err
+	}
+	if assigneeID != nil {
+		order.AssigneeID = assigneeID
+		order.Status = models.OrderStatusAssigned
+	}
+	if err := tx.Create(&order).Error; err != nil {
+		return order, err
+	}
+	if err := recordOrderEvent(tx, OrderActionCreate, OrderActor{UserID: req.CreatedBy}, models.InspectionOrder{}, order, ""); err != nil {
+		return order, err
+	}
+
+	// 创建巡检点确认记录
+	if len(pointIDs) > 0 {
+		checks := make([]models.InspectionPointCheck, 0, len(pointIDs))
+		for i, pointID := range pointIDs {
+			checks = append(checks, models.InspectionPointCheck{
+				OrderID:  order.ID,
+				PointID:  pointID,
+				Sequence: i + 1,
+				Status:   models.CheckStatusPending,
+			})
+		}
+		if err := tx.Create(&checks).Error; err != nil {
+			return order, err
+		}
+	}
+	return order, nil
+}
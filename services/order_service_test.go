@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/models"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestTriggerPlanManuallyWindow(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	viper.Set("inspection.manual_trigger_window", 10*time.Minute)
+	defer viper.Set("inspection.manual_trigger_window", nil)
+
+	// 调度生成的工单不占用手动触发的时间窗口
+	occurrence := time.Now().Add(-time.Minute)
+	if _, err := GenerateInspectionOrder(OrderRequest{Plan: f.plan, Occurrence: occurrence, Scheduled: true}); err != nil {
+		t.Fatal(err)
+	}
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatalf("manual trigger after a scheduled run: %v", err)
+	}
+	if !order.Manual || order.Occurrence != nil {
+		t.Errorf("manual order Manual = %v, Occurrence = %v, want true and nil", order.Manual, order.Occurrence)
+	}
+
+	if _, err := TriggerPlanManually(f.plan.ID, f.assigner.ID); !errors.Is(err, ErrPlanTriggeredRecently) {
+		t.Errorf("second manual trigger error = %v, want ErrPlanTriggeredRecently", err)
+	}
+
+	plan := reload[models.InspectionPlan](t, f.plan.ID)
+	if plan.LastManualAt == nil {
+		t.Error("LastManualAt not recorded")
+	}
+	if plan.LastTriggeredAt != nil {
+		t.Errorf("LastTriggeredAt = %v, want nil", plan.LastTriggeredAt)
+	}
+	if plan.LastOccurrence == nil || !plan.LastOccurrence.Equal(occurrence.Truncate(time.Second)) || plan.OccurrenceCount != 1 {
+		t.Errorf("LastOccurrence = %v, OccurrenceCount = %d, want %v and 1", plan.LastOccurrence, plan.OccurrenceCount, occurrence.Truncate(time.Second))
+	}
+}
+
+func TestTriggerPlanManuallyInactive(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	if err := db.Model(&f.plan).Update("status", models.PlanStatusPaused).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TriggerPlanManually(f.plan.ID, f.assigner.ID); !errors.Is(err, ErrPlanNotActive) {
+		t.Errorf("error = %v, want ErrPlanNotActive", err)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderAction 工单状态迁移动作
+type OrderAction string
+
+const (
+	OrderActionAssign   OrderAction = "assign"
+	OrderActionClaim    OrderAction = "claim"
+	OrderActionStart    OrderAction = "start"
+	OrderActionReject   OrderAction = "reject"
+	OrderActionComplete OrderAction = "complete"
+	OrderActionSubmit   OrderAction = "submit"
+	OrderActionApprove  OrderAction = "approve"
+	OrderActionSendBack OrderAction = "send_back"
+	OrderActionReopen   OrderAction = "reopen"
+	OrderActionCancel   OrderAction = "cancel"
+	OrderActionOverdue  OrderAction = "overdue"
+)
+
+// OrderRole 执行迁移的操作人相对工单的角色
+type OrderRole string
+
+const (
+	OrderRoleSystem   OrderRole = "system"   // 定时任务
+	OrderRoleAssignee OrderRole = "assignee" // 工单执行人
+	OrderRoleManager  OrderRole = "manager"  // 工单派单人或计划派单人
+	OrderRoleReviewer OrderRole = "reviewer" // 计划派单人或具有审核角色的项目成员
+	OrderRoleMember   OrderRole = "member"   // 有项目权限的用户
+)
+
+// orderTransition 某个动作允许的起始状态、目标状态和操作人角色
+type orderTransition struct {
+	from  []models.OrderStatus
+	to    models.OrderStatus
+	roles []OrderRole
+}
+
+// OpenOrderStatuses 尚未结束、仍需执行的工单状态
+var OpenOrderStatuses = []models.OrderStatus{
+	models.OrderStatusPending,
+	models.OrderStatusAssigned,
+	models.OrderStatusInProgress,
+	models.OrderStatusRejected,
+	models.OrderStatusOverdue,
+	models.OrderStatusReopened,
+}
+
+var orderTransitions = map[OrderAction]orderTransition{
+	OrderActionAssign: {
+		from:  []models.OrderStatus{models.OrderStatusPending, models.OrderStatusAssigned, models.OrderStatusRejected, models.OrderStatusOverdue},
+		to:    models.OrderStatusAssigned,
+		roles: []OrderRole{OrderRoleManager},
+	},
+	// 项目成员只能认领未分配的工单，不能把工单分配或改派给其他人
+	OrderActionClaim: {
+		from:  []models.OrderStatus{models.OrderStatusPending, models.OrderStatusRejected, models.OrderStatusOverdue},
+		to:    models.OrderStatusAssigned,
+		roles: []OrderRole{OrderRoleManager, OrderRoleMember},
+	},
+	OrderActionStart: {
+		from:  []models.OrderStatus{models.OrderStatusAssigned, models.OrderStatusOverdue, models.OrderStatusReopened},
+		to:    models.OrderStatusInProgress,
+		roles: []OrderRole{OrderRoleAssignee},
+	},
+	OrderActionReject: {
+		from:  []models.OrderStatus{models.OrderStatusAssigned, models.OrderStatusOverdue},
+		to:    models.OrderStatusRejected,
+		roles: []OrderRole{OrderRoleAssignee},
+	},
+	OrderActionComplete: {
+		from:  []models.OrderStatus{models.OrderStatusInProgress},
+		to:    models.OrderStatusCompleted,
+		roles: []OrderRole{OrderRoleAssignee},
+	},
+	OrderActionSubmit: {
+		from:  []models.OrderStatus{models.OrderStatusInProgress},
+		to:    models.OrderStatusReview,
+		roles: []OrderRole{OrderRoleAssignee},
+	},
+	OrderActionApprove: {
+		from:  []models.OrderStatus{models.OrderStatusReview},
+		to:    models.OrderStatusCompleted,
+		roles: []OrderRole{OrderRoleReviewer},
+	},
+	OrderActionSendBack: {
+		from:  []models.OrderStatus{models.OrderStatusReview},
+		to:    models.OrderStatusReopened,
+		roles: []OrderRole{OrderRoleReviewer},
+	},
+	OrderActionReopen: {
+		from:  []models.OrderStatus{models.OrderStatusCompleted},
+		to:    models.OrderStatusReopened,
+		roles: []OrderRole{OrderRoleManager},
+	},
+	OrderActionCancel: {
+		from:  OpenOrderStatuses,
+		to:    models.OrderStatusCancelled,
+		roles: []OrderRole{OrderRoleManager},
+	},
+	OrderActionOverdue: {
+		from:  []models.OrderStatus{models.OrderStatusPending, models.OrderStatusAssigned, models.OrderStatusRejected},
+		to:    models.OrderStatusOverdue,
+		roles: []OrderRole{OrderRoleSystem},
+	},
+}
+
+var (
+	// ErrOrderActorNotAllowed 操作人无权执行该动作
+	ErrOrderActorNotAllowed = errors.New("无权对该巡检工单执行此操作")
+	// ErrOrderChecksIncomplete 还有巡检点未确认
+	ErrOrderChecksIncomplete = errors.New("还有未确认的巡检点")
+	// ErrOrderAlreadyClaimed 工单已有执行人，不能认领
+	ErrOrderAlreadyClaimed = errors.New("巡检工单已有执行人")
+	// ErrSelfReview 执行人不能审核自己执行的工单
+	ErrSelfReview = fmt.Errorf("%w：不能审核自己执行的巡检工单", ErrOrderActorNotAllowed)
+	// ErrAssigneeNotFound 指定的执行人不存在
+	ErrAssigneeNotFound = errors.New("执行人不存在")
+	// ErrOrderVersionConflict 工单已被其他操作修改，需要重新读取后再提交
+	ErrOrderVersionConflict = errors.New("巡检工单已被修改，请刷新后重试")
+)
+
+// OrderTransitionError 工单当前状态不允许执行该动作
+type OrderTransitionError struct {
+	Action OrderAction
+	From   models.OrderStatus
+}
+
+func (e *OrderTransitionError) Error() string {
+	return fmt.Sprintf("巡检工单当前状态为 %s，不允许执行 %s", e.From, e.Action)
+}
+
+// OrderActor 执行迁移的操作人，UserID 为 0 表示系统
+type OrderActor struct {
+	UserID          uint
+	UserRole        string
+	ProjectMember   bool      // 是否有工单所属项目的权限，由调用方判断
+	ExpectedVersion int       // 客户端读取到的工单版本号，非 0 时必须与当前版本一致
+	At              time.Time // 操作发生的时间，离线同步时为客户端记录的时间，零值表示当前时间
+}
+
+// now 返回操作发生的时间
+func (a OrderActor) now() time.Time {
+	if a.At.IsZero() {
+		return time.Now()
+	}
+	return a.At
+}
+
+// SystemActor 定时任务等系统操作
+var SystemActor = OrderActor{}
+
+// roles 返回操作人相对工单的所有角色
+func (a OrderActor) roles(order models.InspectionOrder) []OrderRole {
+	if a.UserID == 0 {
+		return []OrderRole{OrderRoleSystem}
+	}
+	var roles []OrderRole
+	if order.AssigneeID != nil && *order.AssigneeID == a.UserID {
+		roles = append(roles, OrderRoleAssignee)
+	}
+	if (order.AssignerID != nil && *order.AssignerID == a.UserID) || order.Plan.AssignerID == a.UserID {
+		roles = append(roles, OrderRoleManager)
+	}
+	if order.Plan.AssignerID == a.UserID || (a.ProjectMember && a.UserRole != "" && a.UserRole == reviewerRole()) {
+		roles = append(roles, OrderRoleReviewer)
+	}
+	if a.ProjectMember {
+		roles = append(roles, OrderRoleMember)
+	}
+	return roles
+}
+
+// reviewerRole 返回可审核工单的用户角色
+func reviewerRole() string {
+	if role := viper.GetString("inspection.review.reviewer_role"); role != "" {
+		return role
+	}
+	return "supervisor"
+}
+
+// ValidUserRole 判断是否为系统支持的用户角色：巡检员、主管、审核角色和现场标签设备
+func ValidUserRole(role string) bool {
+	return slices.Contains([]string{models.RoleInspector, models.RoleSupervisor, reviewerRole(), TagDeviceRole()}, role)
+}
+
+// CanTransition 判断工单当前状态是否允许执行该动作
+func CanTransition(status models.OrderStatus, action OrderAction) bool {
+	transition, ok := orderTransitions[action]
+	return ok && slices.Contains(transition.from, status)
+}
+
+// TransitionOrder 在事务中锁定工单，校验状态和操作人后执行迁移并记录到工单时间线；
+// apply 用于在同一事务中完成迁移附带的修改和额外校验，返回错误时整个迁移回滚
+func TransitionOrder(orderID uint, action OrderAction, actor OrderActor, reason string, apply func(tx *gorm.DB, order *models.InspectionOrder) error) (*models.InspectionOrder, error) {
+	transition, ok := orderTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("未知的工单操作: %s", action)
+	}
+
+	var order models.InspectionOrder
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Plan").First(&order, orderID).Error; err != nil {
+			return err
+		}
+
+		if err := checkOrderVersion(order, actor.ExpectedVersion); err != nil {
+			return err
+		}
+		if !slices.Contains(transition.from, order.Status) {
+			return &OrderTransitionError{Action: action, From: order.Status}
+		}
+		if !slices.ContainsFunc(actor.roles(order), func(role OrderRole) bool { return slices.Contains(transition.roles, role) }) {
+			return ErrOrderActorNotAllowed
+		}
+
+		before := order
+		order.Status = transition.to
+		if reason != "" {
+			order.StatusReason = reason
+		}
+		if apply != nil {
+			if err := apply(tx, &order); err != nil {
+				return err
+			}
+		}
+		if err := saveOrder(tx, &order); err != nil {
+			return err
+		}
+		// 复检工单结单或取消后处理对应的整改单
+		if order.DefectID != nil && (order.Status == models.OrderStatusCompleted || order.Status == models.OrderStatusCancelled) {
+			if err := settleVerification(tx, order); err != nil {
+				return err
+			}
+		}
+		return recordOrderEvent(tx, action, actor, before, order, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// AssignOrder 派单人将工单分配或改派给指定执行人，执行人必须有权访问工单所属项目
+func AssignOrder(orderID, assigneeID uint, actor OrderActor) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionAssign, actor, "", func(tx *gorm.DB, order *models.InspectionOrder) error {
+		assignee, err := loadAssignee(tx, assigneeID, order.Plan.ProjectID)
+		if err != nil {
+			return err
+		}
+		order.AssigneeID = &assignee.ID
+		if actor.UserID != 0 {
+			order.AssignerID = &actor.UserID
+		}
+		return nil
+	})
+}
+
+// ClaimOrder 执行人认领未分配的工单，计划设置了执行人列表时只有列表中的人可以认领
+func ClaimOrder(orderID uint, actor OrderActor) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionClaim, actor, "", func(tx *gorm.DB, order *models.InspectionOrder) error {
+		if order.AssigneeID != nil {
+			return ErrOrderAlreadyClaimed
+		}
+		var assignees []models.User
+		if err := tx.Model(&order.Plan).Association("Assignees").Find(&assignees); err != nil {
+			return err
+		}
+		if len(assignees) > 0 && !slices.ContainsFunc(assignees, func(u models.User) bool { return u.ID == actor.UserID }) {
+			return ErrOrderActorNotAllowed
+		}
+		order.AssigneeID = &actor.UserID
+		return nil
+	})
+}
+
+// StartOrder 执行人开始巡检
+func StartOrder(orderID uint, actor OrderActor) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionStart, actor, "", func(tx *gorm.DB, order *models.InspectionOrder) error {
+		// 返工时保留首次开始时间
+		if order.StartTime == nil {
+			now := actor.now()
+			order.StartTime = &now
+		}
+		return nil
+	})
+}
+
+// RejectOrder 执行人拒绝工单，工单回到待分配
+func RejectOrder(orderID uint, actor OrderActor, reason string) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionReject, actor, reason, func(tx *gorm.DB, order *models.InspectionOrder) error {
+		order.AssigneeID = nil
+		return nil
+	})
+}
+
+// CompleteOrder 执行人完成巡检，所有巡检点都已确认后才能完成；计划需要审核时提交审核，否则直接结单
+func CompleteOrder(orderID uint, actor OrderActor, inspectionData string) (*models.InspectionOrder, error) {
+	var order models.InspectionOrder
+	if err := config.DB.Preload("Plan").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	action := OrderActionSubmit
+	if order.Plan.SkipReview {
+		action = OrderActionComplete
+	}
+	return TransitionOrder(orderID, action, actor, "", func(tx *gorm.DB, order *models.InspectionOrder) error {
+		completed, err := countCompletedChecks(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if int(completed) != order.TotalChecks {
+			return ErrOrderChecksIncomplete
+		}
+		now := actor.now()
+		order.EndTime = &now
+		order.InspectionData = inspectionData
+		return nil
+	})
+}
+
+// ApproveOrder 审核通过，工单完成
+func ApproveOrder(orderID uint, actor OrderActor, comment string) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionApprove, actor, "", func(tx *gorm.DB, order *models.InspectionOrder) error {
+		if err := checkNotSelfReview(*order, actor); err != nil {
+			return err
+		}
+		stampReview(order, actor, comment)
+		return nil
+	})
+}
+
+// SendBackOrder 审核不通过，退回执行人返工；pointIDs 为需要重新巡检的点位，为空时重新巡检所有不合格的点位
+func SendBackOrder(orderID uint, actor OrderActor, comment string, pointIDs []uint) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionSendBack, actor, comment, func(tx *gorm.DB, order *models.InspectionOrder) error {
+		if err := checkNotSelfReview(*order, actor); err != nil {
+			return err
+		}
+		stampReview(order, actor, comment)
+		order.EndTime = nil
+
+		query := tx.Model(&models.InspectionPointCheck{}).Where("order_id = ?", order.ID)
+		if len(pointIDs) > 0 {
+			query = query.Where("point_id IN ? AND status <> ?", pointIDs, models.CheckStatusPending)
+		} else {
+			query = query.Where("status = ?", models.CheckStatusFailed)
+		}
+		if err := query.Update("status", models.CheckStatusPending).Error; err != nil {
+			return err
+		}
+		return refreshCompletedChecks(tx, order)
+	})
+}
+
+// checkOrderVersion 校验客户端读取到的版本号，expected 为 0 时不校验
+func checkOrderVersion(order models.InspectionOrder, expected int) error {
+	if expected != 0 && expected != order.Version {
+		return ErrOrderVersionConflict
+	}
+	return nil
+}
+
+// saveOrder 以读取时的版本号为条件保存工单并递增版本号，版本号已变化说明工单已被其他操作修改
+func saveOrder(tx *gorm.DB, order *models.InspectionOrder) error {
+	version := order.Version
+	order.Version++
+	result := tx.Model(order).Select("*").Omit(clause.Associations, "CreatedAt").Where("version = ?", version).Updates(order)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrOrderVersionConflict
+	}
+	if result.Error != nil {
+		order.Version = version
+	}
+	return result.Error
+}
+
+// checkNotSelfReview 计划派单人同时是执行人时，不能审核自己的工单
+func checkNotSelfReview(order models.InspectionOrder, actor OrderActor) error {
+	if order.AssigneeID != nil && *order.AssigneeID == actor.UserID {
+		return ErrSelfReview
+	}
+	return nil
+}
+
+// stampReview 在工单上记录审核人、审核时间和审核意见
+func stampReview(order *models.InspectionOrder, actor OrderActor, comment string) {
+	now := time.Now()
+	order.ReviewedByID = &actor.UserID
+	order.ReviewedAt = &now
+	order.ReviewComment = comment
+}
+
+// ReopenOrder 重新打开已完成的工单返工
+func ReopenOrder(orderID uint, actor OrderActor, reason string) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionReopen, actor, reason, nil)
+}
+
+// CancelOrder 取消工单
+func CancelOrder(orderID uint, actor OrderActor, reason string) (*models.InspectionOrder, error) {
+	return TransitionOrder(orderID, OrderActionCancel, actor, reason, nil)
+}
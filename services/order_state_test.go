@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/models"
+	"slices"
+	"testing"
+)
+
+func TestTransitionOrderRejectsIllegalMove(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = TransitionOrder(order.ID, OrderActionComplete, f.assigneeActor(), "", nil)
+	var transitionErr *OrderTransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("complete an assigned order: error = %v, want *OrderTransitionError", err)
+	}
+	if transitionErr.From != models.OrderStatusAssigned || transitionErr.Action != OrderActionComplete {
+		t.Errorf("transition error = %+v, want complete from assigned", transitionErr)
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.Status != models.OrderStatusAssigned || got.Version != order.Version {
+		t.Errorf("order after illegal move: status = %s, version = %d, want unchanged", got.Status, got.Version)
+	}
+}
+
+func TestTransitionOrderStaleVersion(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale := f.assigneeActor()
+	stale.ExpectedVersion = order.Version + 1
+	if _, err := StartOrder(order.ID, stale); !errors.Is(err, ErrOrderVersionConflict) {
+		t.Errorf("start with stale version: error = %v, want ErrOrderVersionConflict", err)
+	}
+
+	current := f.assigneeActor()
+	current.ExpectedVersion = order.Version
+	started, err := StartOrder(order.ID, current)
+	if err != nil {
+		t.Fatalf("start with current version: %v", err)
+	}
+	if started.Version != order.Version+1 {
+		t.Errorf("version after start = %d, want %d", started.Version, order.Version+1)
+	}
+}
+
+func TestRejectOrderClearsAssignee(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := RejectOrder(order.ID, f.assigneeActor(), "设备停机")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reload[models.InspectionOrder](t, rejected.ID)
+	if got.Status != models.OrderStatusRejected || got.AssigneeID != nil || got.StatusReason != "设备停机" {
+		t.Errorf("rejected order status = %s, assignee = %v, reason = %q", got.Status, got.AssigneeID, got.StatusReason)
+	}
+
+	// 拒绝后执行人不能再开始该工单
+	if _, err := StartOrder(order.ID, f.assigneeActor()); err == nil {
+		t.Error("former assignee started a rejected order")
+	}
+}
+
+func TestAssignOrder(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := models.Project{Name: "其他项目"}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	outsider := models.User{Username: "outsider", Email: "outsider@example.com", Password: "x", ProjectID: &other.ID}
+	member := models.User{Username: "member", Email: "member@example.com", Password: "x", Role: models.RoleInspector, ProjectID: &f.project.ID}
+	if err := db.Create(&outsider).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+	memberActor := OrderActor{UserID: member.ID, UserRole: member.Role, ProjectMember: true}
+
+	tests := []struct {
+		name     string
+		actor    OrderActor
+		assignee uint
+		want     error
+	}{
+		{"member reassigns to self", memberActor, member.ID, ErrOrderActorNotAllowed},
+		{"assignee reassigns", f.assigneeActor(), member.ID, ErrOrderActorNotAllowed},
+		{"user from another project", f.assignerActor(), outsider.ID, ErrAssigneeNoProjectAccess},
+		{"missing user", f.assignerActor(), 999, ErrAssigneeNotFound},
+		{"manager reassigns", f.assignerActor(), member.ID, nil},
+	}
+	for _, tt := range tests {
+		_, err := AssignOrder(order.ID, tt.assignee, tt.actor)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.AssigneeID == nil || *got.AssigneeID != member.ID {
+		t.Errorf("assignee = %v, want %d", got.AssigneeID, member.ID)
+	}
+
+	// 被拒绝的工单由项目成员认领
+	if _, err := RejectOrder(order.ID, memberActor, ""); err != nil {
+		t.Fatal(err)
+	}
+	claimed, err := ClaimOrder(order.ID, f.assigneeActor())
+	if err != nil {
+		t.Fatalf("member claims a rejected order: %v", err)
+	}
+	if claimed.AssigneeID == nil || *claimed.AssigneeID != f.assignee.ID {
+		t.Errorf("claimed assignee = %v, want %d", claimed.AssigneeID, f.assignee.ID)
+	}
+}
+
+func TestCancelledOrderIsTerminal(t *testing.T) {
+	for action := range orderTransitions {
+		if CanTransition(models.OrderStatusCancelled, action) {
+			t.Errorf("cancelled order allows %s", action)
+		}
+	}
+}
+
+func TestOrderActorRoles(t *testing.T) {
+	assignee, assigner, planAssigner := uint(10), uint(20), uint(30)
+	order := models.InspectionOrder{
+		AssigneeID: &assignee,
+		AssignerID: &assigner,
+		Plan:       models.InspectionPlan{AssignerID: planAssigner},
+	}
+	tests := []struct {
+		name  string
+		actor OrderActor
+		want  []OrderRole
+	}{
+		{"system", SystemActor, []OrderRole{OrderRoleSystem}},
+		{"assignee", OrderActor{UserID: assignee, ProjectMember: true}, []OrderRole{OrderRoleAssignee, OrderRoleMember}},
+		{"order assigner", OrderActor{UserID: assigner, ProjectMember: true}, []OrderRole{OrderRoleManager, OrderRoleMember}},
+		{"plan assigner", OrderActor{UserID: planAssigner, ProjectMember: true}, []OrderRole{OrderRoleManager, OrderRoleReviewer, OrderRoleMember}},
+		{"supervisor", OrderActor{UserID: 40, UserRole: models.RoleSupervisor, ProjectMember: true}, []OrderRole{OrderRoleReviewer, OrderRoleMember}},
+		{"supervisor outside project", OrderActor{UserID: 40, UserRole: models.RoleSupervisor}, nil},
+		{"inspector", OrderActor{UserID: 50, UserRole: models.RoleInspector, ProjectMember: true}, []OrderRole{OrderRoleMember}},
+		{"assignee outside project", OrderActor{UserID: assignee}, []OrderRole{OrderRoleAssignee}},
+	}
+	for _, tt := range tests {
+		if got := tt.actor.roles(order); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: roles = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckNotSelfReview(t *testing.T) {
+	assignee := uint(10)
+	order := models.InspectionOrder{AssigneeID: &assignee}
+	if err := checkNotSelfReview(order, OrderActor{UserID: assignee}); !errors.Is(err, ErrSelfReview) || !errors.Is(err, ErrOrderActorNotAllowed) {
+		t.Errorf("self review error = %v, want ErrSelfReview", err)
+	}
+	if err := checkNotSelfReview(order, OrderActor{UserID: 20}); err != nil {
+		t.Errorf("review by other user error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// 升级链中可配置的升级对象
+const (
+	EscalateToAssignee     = "assignee"      // 工单执行人
+	EscalateToAssigner     = "assigner"      // 计划的派单人
+	EscalateToProjectOwner = "project_owner" // 项目负责人
+)
+
+// defaultEscalationChain 未配置 inspection.escalation.chain 时的升级链
+var defaultEscalationChain = []string{EscalateToAssignee, EscalateToAssigner, EscalateToProjectOwner}
+
+// escalationChain 返回配置的升级链
+func escalationChain() []string {
+	chain := viper.GetStringSlice("inspection.escalation.chain")
+	if len(chain) == 0 {
+		return defaultEscalationChain
+	}
+	return chain
+}
+
+// escalationInterval 返回逾期后每升级一级的间隔
+func escalationInterval() time.Duration {
+	interval := viper.GetDuration("inspection.escalation.interval")
+	if interval <= 0 {
+		return 4 * time.Hour
+	}
+	return interval
+}
+
+// DueTime 按计划的完成时限计算工单的截止时间，未设置时限时返回 nil
+func DueTime(plan models.InspectionPlan, scheduledAt time.Time) *time.Time {
+	if plan.CompletionHours <= 0 {
+		return nil
+	}
+	due := scheduledAt.Add(time.Duration(plan.CompletionHours) * time.Hour)
+	return &due
+}
+
+// EscalateOverdueOrders 标记已过截止时间仍未完成的工单为逾期（尚未开始的转为逾期状态），并按升级链逐级升级
+// 逾期即升级到第一级，此后每经过一个升级间隔再升一级，直到升级链末尾
+func EscalateOverdueOrders(now time.Time) error {
+	var orders []models.InspectionOrder
+	if err := config.DB.Preload("Plan").Preload("Plan.Project").
+		Where("due_at < ? AND status IN ?", now, OpenOrderStatuses).
+		Find(&orders).Error; err != nil {
+		return err
+	}
+
+	chain := escalationChain()
+	interval := escalationInterval()
+	for _, order := range orders {
+		// 尚未开始的工单转为逾期状态
+		if CanTransition(order.Status, OrderActionOverdue) {
+			if updated, err := TransitionOrder(order.ID, OrderActionOverdue, SystemActor, "", nil); err != nil {
+				log.Printf("巡检工单 %d 标记逾期失败: %v", order.ID, err)
+			} else {
+				order.Status = updated.Status
+			}
+		}
+
+		level := min(len(chain), 1+int(now.Sub(*order.DueAt)/interval))
+		if order.OverdueAt != nil && level <= order.EscalationLevel {
+			continue
+		}
+
+		updates := map[string]interface{}{"version": gorm.Expr("version + 1")}
+		if order.OverdueAt == nil {
+			updates["overdue_at"] = now
+		}
+		reached := order.EscalationLevel
+		var targetID *uint
+		if level > order.EscalationLevel {
+			// 当前级别无对应人员时继续向后查找
+			targetID, reached = escalationTarget(order, chain, level)
+			updates["escalation_level"] = reached
+			updates["escalated_to_id"] = targetID
+			updates["escalated_at"] = now
+		}
+
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.InspectionOrder{}).Where("id = ?", order.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if reached == order.EscalationLevel {
+				return nil
+			}
+			escalated := order
+			escalated.EscalationLevel = reached
+			escalated.EscalatedToID = targetID
+			escalated.EscalatedAt = &now
+			return recordOrderEvent(tx, OrderActionEscalate, SystemActor, order, escalated, fmt.Sprintf("逾期升级到第 %d 级", reached))
+		})
+		if err != nil {
+			log.Printf("巡检工单 %d 逾期升级失败: %v", order.ID, err)
+			continue
+		}
+		if targetID != nil {
+			log.Printf("巡检工单 %d 已逾期，升级到第 %d 级（用户 %d）", order.ID, reached, *targetID)
+		} else {
+			log.Printf("巡检工单 %d 已逾期，升级到第 %d 级", order.ID, reached)
+		}
+	}
+	return nil
+}
+
+// escalationTarget 返回升级链第 level 级（从 1 开始）对应的人员；该级无人时顺延到后续级别，
+// 返回实际到达的级别
+func escalationTarget(order models.InspectionOrder, chain []string, level int) (*uint, int) {
+	for i := level; i <= len(chain); i++ {
+		var target *uint
+		switch chain[i-1] {
+		case EscalateToAssignee:
+			target = order.AssigneeID
+		case EscalateToAssigner:
+			if order.Plan.AssignerID != 0 {
+				target = &order.Plan.AssignerID
+			}
+		case EscalateToProjectOwner:
+			target = order.Plan.Project.OwnerID
+		}
+		if target != nil {
+			return target, i
+		}
+	}
+	return nil, level
+}
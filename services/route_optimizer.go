@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxTwoOptRounds 2-opt 优化的最大轮数，避免点位很多时耗时过长
+	maxTwoOptRounds = 100
+	// MaxOptimizedPoints 可以自动优化的路线点位数上限，距离矩阵和 2-opt 的耗时随点位数平方增长
+	MaxOptimizedPoints = 300
+)
+
+var (
+	// ErrPointsMissingCoordinates 路线中有点位未设置经纬度，无法计算距离
+	ErrPointsMissingCoordinates = errors.New("以下巡检点未设置经纬度")
+	// ErrRouteEndpointInvalid 指定的起点或终点不在路线中
+	ErrRouteEndpointInvalid = errors.New("指定的起点或终点不在路线中")
+	// ErrRouteTooLarge 路线点位过多，无法自动优化
+	ErrRouteTooLarge = fmt.Errorf("路线点位超过 %d 个，无法自动优化", MaxOptimizedPoints)
+)
+
+// RouteOptimization 路线优化的结果，距离单位为米
+type RouteOptimization struct {
+	PointIDs       []uint  `json:"point_ids"` // 建议的巡检顺序
+	BeforeDistance float64 `json:"before_distance"`
+	AfterDistance  float64 `json:"after_distance"`
+	Applied        bool    `json:"applied"`
+}
+
+// OptimizeRoute 根据点位坐标计算路线的最短巡检顺序（最近邻构造后以 2-opt 改进）。
+// startPointID 为 0 时以路线当前的第一个点位为起点；endPointID 不为 0 时固定为终点。apply 为 true 时按建议顺序保存路线
+func OptimizeRoute(routeID, startPointID, endPointID uint, apply bool) (*RouteOptimization, error) {
+	points, err := RoutePoints(config.DB, routeID)
+	if err != nil {
+		return nil, err
+	}
+	result := &RouteOptimization{PointIDs: []uint{}}
+	if len(points) == 0 {
+		return result, nil
+	}
+	if len(points) > MaxOptimizedPoints {
+		return nil, ErrRouteTooLarge
+	}
+
+	coords := make([]Coordinate, len(points))
+	var missing []string
+	for i, point := range points {
+		coord, ok := PointCoordinate(point)
+		if !ok {
+			missing = append(missing, point.Name)
+		}
+		coords[i] = coord
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w：%s", ErrPointsMissingCoordinates, strings.Join(missing, "、"))
+	}
+
+	indexOf := func(pointID uint) int {
+		return slices.IndexFunc(points, func(p models.InspectionPoint) bool { return p.ID == pointID })
+	}
+	start, end := 0, -1
+	if startPointID != 0 {
+		if start = indexOf(startPointID); start < 0 {
+			return nil, ErrRouteEndpointInvalid
+		}
+	}
+	if endPointID != 0 {
+		if end = indexOf(endPointID); end < 0 || end == start {
+			return nil, ErrRouteEndpointInvalid
+		}
+	}
+
+	dist := make([][]float64, len(coords))
+	for i := range coords {
+		dist[i] = make([]float64, len(coords))
+		for j := range coords {
+			dist[i][j] = DistanceMeters(coords[i], coords[j])
+		}
+	}
+
+	current := make([]int, len(points))
+	for i := range current {
+		current[i] = i
+	}
+	result.BeforeDistance = pathDistance(current, dist)
+
+	path := nearestNeighbourPath(dist, start, end)
+	twoOpt(path, dist, end >= 0)
+	result.AfterDistance = pathDistance(path, dist)
+
+	// 当前顺序已满足起点、终点要求且不比建议的更长时保持不变
+	if current[0] == start && (end < 0 || current[len(current)-1] == end) && result.BeforeDistance <= result.AfterDistance {
+		path, result.AfterDistance = current, result.BeforeDistance
+	}
+	for _, i := range path {
+		result.PointIDs = append(result.PointIDs, points[i].ID)
+	}
+
+	if apply {
+		if err := ReorderRoutePoints(routeID, result.PointIDs); err != nil {
+			return nil, err
+		}
+		result.Applied = true
+	}
+	return result, nil
+}
+
+// nearestNeighbourPath 从 start 出发每次前往最近的未访问点位，end 不为 -1 时最后到达 end
+func nearestNeighbourPath(dist [][]float64, start, end int) []int {
+	n := len(dist)
+	visited := make([]bool, n)
+	visited[start] = true
+	if end >= 0 {
+		visited[end] = true
+	}
+	path := []int{start}
+	for cur := start; ; {
+		next := -1
+		for j := 0; j < n; j++ {
+			if !visited[j] && (next < 0 || dist[cur][j] < dist[cur][next]) {
+				next = j
+			}
+		}
+		if next < 0 {
+			break
+		}
+		visited[next] = true
+		path = append(path, next)
+		cur = next
+	}
+	if end >= 0 {
+		path = append(path, end)
+	}
+	return path
+}
+
+// twoOpt 反转路径中的片段直到无法再缩短，起点固定，fixedEnd 为 true 时终点也固定
+func twoOpt(path []int, dist [][]float64, fixedEnd bool) {
+	n := len(path)
+	last := n - 1
+	if fixedEnd {
+		last = n - 2
+	}
+	for round := 0; round < maxTwoOptRounds; round++ {
+		improved := false
+		for i := 1; i < last; i++ {
+			for k := i + 1; k <= last; k++ {
+				delta := dist[path[i-1]][path[k]] - dist[path[i-1]][path[i]]
+				if k+1 < n {
+					delta += dist[path[i]][path[k+1]] - dist[path[k]][path[k+1]]
+				}
+				if delta < -1e-6 {
+					slices.Reverse(path[i : k+1])
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+// pathDistance 按顺序依次经过各点位的总距离
+func pathDistance(path []int, dist [][]float64) float64 {
+	total := 0.0
+	for i := 1; i < len(path); i++ {
+		total += dist[path[i-1]][path[i]]
+	}
+	return total
+}
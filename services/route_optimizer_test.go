@@ -0,0 +1,160 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// planeDistances 按平面直线距离生成距离矩阵
+func planeDistances(points [][2]float64) [][]float64 {
+	dist := make([][]float64, len(points))
+	for i := range points {
+		dist[i] = make([]float64, len(points))
+		for j := range points {
+			dist[i][j] = math.Hypot(points[i][0]-points[j][0], points[i][1]-points[j][1])
+		}
+	}
+	return dist
+}
+
+// shortestPath 穷举求起点固定、终点可选固定时的最短路径长度
+func shortestPath(dist [][]float64, start, end int) float64 {
+	var middle []int
+	for i := range dist {
+		if i != start && i != end {
+			middle = append(middle, i)
+		}
+	}
+	best := math.Inf(1)
+	var permute func(k int)
+	permute = func(k int) {
+		if k == len(middle) {
+			path := append([]int{start}, middle...)
+			if end >= 0 {
+				path = append(path, end)
+			}
+			best = math.Min(best, pathDistance(path, dist))
+			return
+		}
+		for i := k; i < len(middle); i++ {
+			middle[k], middle[i] = middle[i], middle[k]
+			permute(k + 1)
+			middle[k], middle[i] = middle[i], middle[k]
+		}
+	}
+	permute(0)
+	return best
+}
+
+func TestPathDistance(t *testing.T) {
+	dist := planeDistances([][2]float64{{0, 0}, {3, 0}, {3, 4}})
+	tests := []struct {
+		path []int
+		want float64
+	}{
+		{nil, 0},
+		{[]int{0}, 0},
+		{[]int{0, 1}, 3},
+		{[]int{0, 1, 2}, 7},
+		{[]int{0, 2, 1}, 9},
+	}
+	for _, tt := range tests {
+		if got := pathDistance(tt.path, dist); got != tt.want {
+			t.Errorf("pathDistance(%v) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestOptimizedPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		points     [][2]float64
+		start, end int
+		wantNN     []int
+		want       []int
+	}{
+		{
+			name:   "collinear in shuffled order",
+			points: [][2]float64{{0, 0}, {3, 0}, {1, 0}, {4, 0}, {2, 0}},
+			start:  0,
+			end:    -1,
+			wantNN: []int{0, 2, 4, 1, 3},
+			want:   []int{0, 2, 4, 1, 3},
+		},
+		{
+			name:   "nearest neighbour zigzag",
+			points: [][2]float64{{0, 0}, {1, 0}, {-1.8, 0}, {4.5, 0}, {-9, 0}},
+			start:  0,
+			end:    -1,
+			wantNN: []int{0, 1, 2, 3, 4},
+		},
+		{
+			name:   "fixed end in the middle",
+			points: [][2]float64{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}},
+			start:  0,
+			end:    2,
+			wantNN: []int{0, 1, 3, 4, 2},
+		},
+		{
+			name:   "fixed start in the middle",
+			points: [][2]float64{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}},
+			start:  2,
+			end:    4,
+			wantNN: []int{2, 1, 0, 3, 4},
+			want:   []int{2, 1, 0, 3, 4},
+		},
+		{
+			name:   "crossing edges",
+			points: [][2]float64{{0, 0}, {0, 1}, {2, 0}, {2, 1}, {4, 0}, {4, 1}, {6, 0}},
+			start:  0,
+			end:    6,
+		},
+		{
+			name:   "start equals the only other point",
+			points: [][2]float64{{0, 0}, {5, 5}},
+			start:  1,
+			end:    0,
+			wantNN: []int{1, 0},
+			want:   []int{1, 0},
+		},
+	}
+	for _, tt := range tests {
+		dist := planeDistances(tt.points)
+		nn := nearestNeighbourPath(dist, tt.start, tt.end)
+		if tt.wantNN != nil && !slices.Equal(nn, tt.wantNN) {
+			t.Errorf("%s: nearestNeighbourPath = %v, want %v", tt.name, nn, tt.wantNN)
+		}
+		path := slices.Clone(nn)
+		twoOpt(path, dist, tt.end >= 0)
+
+		if len(path) != len(tt.points) {
+			t.Errorf("%s: path %v does not visit all %d points", tt.name, path, len(tt.points))
+			continue
+		}
+		sorted := slices.Clone(path)
+		slices.Sort(sorted)
+		for i, p := range sorted {
+			if p != i {
+				t.Errorf("%s: path %v is not a permutation", tt.name, path)
+				break
+			}
+		}
+		if path[0] != tt.start {
+			t.Errorf("%s: path %v starts at %d, want %d", tt.name, path, path[0], tt.start)
+		}
+		if tt.end >= 0 && path[len(path)-1] != tt.end {
+			t.Errorf("%s: path %v ends at %d, want %d", tt.name, path, path[len(path)-1], tt.end)
+		}
+		if tt.want != nil && !slices.Equal(path, tt.want) {
+			t.Errorf("%s: twoOpt = %v, want %v", tt.name, path, tt.want)
+		}
+		got, best := pathDistance(path, dist), shortestPath(dist, tt.start, tt.end)
+		if got > pathDistance(nn, dist)+1e-9 {
+			t.Errorf("%s: twoOpt lengthened the path from %v to %v", tt.name, pathDistance(nn, dist), got)
+		}
+		if math.Abs(got-best) > 1e-9 {
+			t.Errorf("%s: distance = %v, want shortest %v", tt.name, got, best)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPointAlreadyInRoute 巡检点已在路线中
+	ErrPointAlreadyInRoute = errors.New("巡检点已在路线中")
+	// ErrPointNotInRoute 巡检点不在路线中
+	ErrPointNotInRoute = errors.New("巡检点不在路线中")
+	// ErrInvalidRouteOrder 排序后的点位必须与路线现有点位完全一致
+	ErrInvalidRouteOrder = errors.New("排序的巡检点与路线中的巡检点不一致")
+	// ErrCheckOutOfSequence 路线要求按顺序巡检，前面还有未确认的点位
+	ErrCheckOutOfSequence = fmt.Errorf("%w：请按路线顺序确认巡检点", ErrInvalidCheckInput)
+)
+
+// routeStops 按顺序返回路线的点位关联，顺序相同（如升级前的数据）时按点位ID排列
+func routeStops(db *gorm.DB, routeIDs ...uint) ([]models.RoutePoint, error) {
+	var stops []models.RoutePoint
+	err := db.Where("inspection_route_id IN ?", routeIDs).
+		Order("inspection_route_id, position, inspection_point_id").
+		Find(&stops).Error
+	return stops, err
+}
+
+// RoutePoints 按路线顺序返回路线的巡检点
+func RoutePoints(db *gorm.DB, routeID uint) ([]models.InspectionPoint, error) {
+	routes := []models.InspectionRoute{{Model: gorm.Model{ID: routeID}}}
+	if err := LoadRouteStops(db, routes); err != nil {
+		return nil, err
+	}
+	return routes[0].Points, nil
+}
+
+// LoadRouteStops 为路线加载按顺序排列的点位及各点位的预计停留时间
+func LoadRouteStops(db *gorm.DB, routes []models.InspectionRoute) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	routeIDs := make([]uint, 0, len(routes))
+	for _, route := range routes {
+		routeIDs = append(routeIDs, route.ID)
+	}
+	stops, err := routeStops(db, routeIDs...)
+	if err != nil {
+		return err
+	}
+
+	pointIDs := make([]uint, 0, len(stops))
+	for _, stop := range stops {
+		pointIDs = append(pointIDs, stop.InspectionPointID)
+	}
+	var points []models.InspectionPoint
+	if len(pointIDs) > 0 {
+		if err := db.Find(&points, pointIDs).Error; err != nil {
+			return err
+		}
+	}
+	byID := make(map[uint]models.InspectionPoint, len(points))
+	for _, point := range points {
+		byID[point.ID] = point
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		route.Points, route.Stops, route.EstimatedMinutes = []models.InspectionPoint{}, []models.RoutePoint{}, 0
+		for _, stop := range stops {
+			point, ok := byID[stop.InspectionPointID]
+			if stop.InspectionRouteID != route.ID || !ok {
+				continue
+			}
+			route.Points = append(route.Points, point)
+			route.Stops = append(route.Stops, stop)
+			route.EstimatedMinutes += stop.ExpectedMinutes
+		}
+	}
+	return nil
+}
+
+// InsertRoutePoint 将巡检点插入路线的指定位置（从 1 开始），position 为 0 或超出末尾时追加到最后
+func InsertRoutePoint(routeID, pointID uint, position, expectedMinutes int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		stops, err := lockRouteStops(tx, routeID)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(stops, func(stop models.RoutePoint) bool { return stop.InspectionPointID == pointID }) {
+			return ErrPointAlreadyInRoute
+		}
+
+		if position <= 0 || position > len(stops) {
+			position = len(stops) + 1
+		}
+		ids := stopPointIDs(stops)
+		ids = slices.Insert(ids, position-1, pointID)
+		stop := models.RoutePoint{InspectionRouteID: routeID, InspectionPointID: pointID, Position: position, ExpectedMinutes: expectedMinutes}
+		if err := tx.Create(&stop).Error; err != nil {
+			return err
+		}
+		return writeRoutePositions(tx, routeID, ids)
+	})
+}
+
+// RemoveRoutePoint 从路线移除巡检点，其后的点位依次前移
+func RemoveRoutePoint(routeID, pointID uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		stops, err := lockRouteStops(tx, routeID)
+		if err != nil {
+			return err
+		}
+		ids := stopPointIDs(stops)
+		i := slices.Index(ids, pointID)
+		if i < 0 {
+			return ErrPointNotInRoute
+		}
+		if err := tx.Where("inspection_route_id = ? AND inspection_point_id = ?", routeID, pointID).Delete(&models.RoutePoint{}).Error; err != nil {
+			return err
+		}
+		return writeRoutePositions(tx, routeID, slices.Delete(ids, i, i+1))
+	})
+}
+
+// ReorderRoutePoints 按给定的点位顺序重新排列路线，pointIDs 必须包含路线中的全部点位
+func ReorderRoutePoints(routeID uint, pointIDs []uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		stops, err := lockRouteStops(tx, routeID)
+		if err != nil {
+			return err
+		}
+		current := stopPointIDs(stops)
+		sorted := slices.Clone(pointIDs)
+		slices.Sort(current)
+		slices.Sort(sorted)
+		if !slices.Equal(current, sorted) {
+			return ErrInvalidRouteOrder
+		}
+		return writeRoutePositions(tx, routeID, pointIDs)
+	})
+}
+
+// UpdateRouteStop 修改点位的预计停留时间，position 大于 0 时同时移动到该位置
+func UpdateRouteStop(routeID, pointID uint, position int, expectedMinutes *int) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		stops, err := lockRouteStops(tx, routeID)
+		if err != nil {
+			return err
+		}
+		ids := stopPointIDs(stops)
+		i := slices.Index(ids, pointID)
+		if i < 0 {
+			return ErrPointNotInRoute
+		}
+
+		if expectedMinutes != nil {
+			if err := tx.Model(&models.RoutePoint{}).
+				Where("inspection_route_id = ? AND inspection_point_id = ?", routeID, pointID).
+				Update("expected_minutes", *expectedMinutes).Error; err != nil {
+				return err
+			}
+		}
+		if position > 0 {
+			ids = slices.Delete(ids, i, i+1)
+			ids = slices.Insert(ids, min(position, len(ids)+1)-1, pointID)
+		}
+		return writeRoutePositions(tx, routeID, ids)
+	})
+}
+
+// lockRouteStops 锁定路线后按顺序读取点位关联，同一路线的调整依次执行
+func lockRouteStops(tx *gorm.DB, routeID uint) ([]models.RoutePoint, error) {
+	var route models.InspectionRoute
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&route, routeID).Error; err != nil {
+		return nil, err
+	}
+	return routeStops(tx, routeID)
+}
+
+func stopPointIDs(stops []models.RoutePoint) []uint {
+	ids := make([]uint, 0, len(stops))
+	for _, stop := range stops {
+		ids = append(ids, stop.InspectionPointID)
+	}
+	return ids
+}
+
+// writeRoutePositions 按 pointIDs 的顺序将各点位的位置重写为 1..n
+func writeRoutePositions(tx *gorm.DB, routeID uint, pointIDs []uint) error {
+	for i, pointID := range pointIDs {
+		if err := tx.Model(&models.RoutePoint{}).
+			Where("inspection_route_id = ? AND inspection_point_id = ?", routeID, pointID).
+			Update("position", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSequence 路线要求按顺序巡检时，确认前面的点位都已确认；工单固定了路线版本时以该版本的设置为准
+func checkSequence(tx *gorm.DB, order models.InspectionOrder, version *models.RouteVersion, check models.InspectionPointCheck) error {
+	enforce := false
+	if version != nil {
+		enforce = version.EnforceSequence
+	} else {
+		var route models.InspectionRoute
+		if err := tx.First(&route, order.Plan.RouteID).Error; err != nil {
+			return err
+		}
+		enforce = route.EnforceSequence
+	}
+	if !enforce || check.Sequence <= 1 {
+		return nil
+	}
+
+	var pending int64
+	if err := tx.Model(&models.InspectionPointCheck{}).
+		Where("order_id = ? AND sequence < ? AND status = ?", order.ID, check.Sequence, models.CheckStatusPending).
+		Count(&pending).Error; err != nil {
+		return err
+	}
+	if pending > 0 {
+		return ErrCheckOutOfSequence
+	}
+	return nil
+}
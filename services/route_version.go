@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRouteVersionNotFound 路线没有该版本
+var ErrRouteVersionNotFound = errors.New("路线版本不存在")
+
+// PublishRouteVersion 为路线当前的点位顺序和巡检项清单生成版本，内容与最新版本相同时直接返回最新版本
+func PublishRouteVersion(routeID uint) (*models.RouteVersion, error) {
+	var version *models.RouteVersion
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		version, err = pinRouteVersion(tx, routeID)
+		return err
+	})
+	return version, err
+}
+
+// pinRouteVersion 在事务中锁定路线并返回与当前内容一致的版本，必要时新建版本
+func pinRouteVersion(tx *gorm.DB, routeID uint) (*models.RouteVersion, error) {
+	var route models.InspectionRoute
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&route, routeID).Error; err != nil {
+		return nil, err
+	}
+	snapshot, err := snapshotRoute(tx, route)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest models.RouteVersion
+	err = tx.Where("route_id = ?", routeID).Order("version DESC").First(&latest).Error
+	switch {
+	case err == nil:
+		if latest.Checksum == snapshot.Checksum {
+			return &latest, nil
+		}
+		snapshot.Version = latest.Version + 1
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		snapshot.Version = 1
+	default:
+		return nil, err
+	}
+	if err := tx.Create(&snapshot).Error; err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// snapshotRoute 按路线顺序生成路线及各点位巡检项的快照（未保存）
+func snapshotRoute(tx *gorm.DB, route models.InspectionRoute) (models.RouteVersion, error) {
+	routes := []models.InspectionRoute{route}
+	if err := LoadRouteStops(tx, routes); err != nil {
+		return models.RouteVersion{}, err
+	}
+	route = routes[0]
+
+	items := make(map[uint][]models.InspectionItem)
+	if pointIDs := stopPointIDs(route.Stops); len(pointIDs) > 0 {
+		var points []models.InspectionPoint
+		if err := tx.Preload("Items").Find(&points, pointIDs).Error; err != nil {
+			return models.RouteVersion{}, err
+		}
+		for _, point := range points {
+			items[point.ID] = point.Items
+		}
+	}
+
+	version := models.RouteVersion{
+		RouteID:         route.ID,
+		Name:            route.Name,
+		Description:     route.Description,
+		EnforceSequence: route.EnforceSequence,
+		Points:          make([]models.PointSnapshot, 0, len(route.Points)),
+	}
+	for i, point := range route.Points {
+		snapshot := models.PointSnapshot{
+			PointID:         point.ID,
+			Position:        i + 1,
+			ExpectedMinutes: route.Stops[i].ExpectedMinutes,
+			Name:            point.Name,
+			Description:     point.Description,
+			Location:        point.Location,
+			Floor:           point.Floor,
+			Zone:            point.Zone,
+			Items:           []models.ItemSnapshot{},
+		}
+		pointItems := slices.Clone(items[point.ID])
+		slices.SortFunc(pointItems, func(a, b models.InspectionItem) int { return int(a.ID) - int(b.ID) })
+		for _, item := range pointItems {
+			snapshot.Items = append(snapshot.Items, models.ItemSnapshot{
+				ItemID:          item.ID,
+				Title:           item.Title,
+				Details:         item.Details,
+				ExecutionMethod: item.ExecutionMethod,
+				Type:            item.Type,
+				Unit:            item.Unit,
+				MinValue:        item.MinValue,
+				MaxValue:        item.MaxValue,
+				Options:         item.Options,
+			})
+		}
+		version.Points = append(version.Points, snapshot)
+	}
+
+	content, err := json.Marshal(struct {
+		Name            string                 `json:"name"`
+		Description     string                 `json:"description"`
+		EnforceSequence bool                   `json:"enforce_sequence"`
+		Points          []models.PointSnapshot `json:"points"`
+	}{version.Name, version.Description, version.EnforceSequence, version.Points})
+	if err != nil {
+		return models.RouteVersion{}, err
+	}
+	sum := sha256.Sum256(content)
+	version.Checksum = hex.EncodeToString(sum[:])
+	return version, nil
+}
+
+// RouteVersions 按版本号倒序返回路线的全部版本，不含点位快照
+func RouteVersions(routeID uint) ([]models.RouteVersion, error) {
+	var versions []models.RouteVersion
+	err := config.DB.Omit("points").Where("route_id = ?", routeID).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+// GetRouteVersion 返回路线的指定版本
+func GetRouteVersion(routeID uint, version int) (*models.RouteVersion, error) {
+	var routeVersion models.RouteVersion
+	if err := config.DB.Where("route_id = ? AND version = ?", routeID, version).First(&routeVersion).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRouteVersionNotFound
+		}
+		return nil, err
+	}
+	return &routeVersion, nil
+}
+
+// orderRouteVersion 返回工单生成时固定的路线版本，升级前生成的工单返回 nil
+func orderRouteVersion(tx *gorm.DB, order models.InspectionOrder) (*models.RouteVersion, error) {
+	if order.RouteVersionID == nil {
+		return nil, nil
+	}
+	var version models.RouteVersion
+	if err := tx.First(&version, *order.RouteVersionID).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// orderPoint 返回工单中要巡检的点位：名称和巡检项清单取自工单固定的路线版本，
+// 标签码、坐标和地理围栏属于现场设施，仍使用当前设置；没有固定版本时全部使用当前设置
+func orderPoint(tx *gorm.DB, version *models.RouteVersion, pointID uint) (models.InspectionPoint, error) {
+	var point models.InspectionPoint
+	if version == nil {
+		err := tx.Preload("Items").First(&point, pointID).Error
+		return point, err
+	}
+	if err := tx.Unscoped().First(&point, pointID).Error; err != nil {
+		return point, err
+	}
+
+	i := slices.IndexFunc(version.Points, func(p models.PointSnapshot) bool { return p.PointID == pointID })
+	if i < 0 {
+		return point, ErrPointNotInRoute
+	}
+	snapshot := version.Points[i]
+	point.Name = snapshot.Name
+	point.Description = snapshot.Description
+	point.Location = snapshot.Location
+	point.Floor = snapshot.Floor
+	point.Zone = snapshot.Zone
+	point.Items = make([]models.InspectionItem, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		point.Items = append(point.Items, models.InspectionItem{
+			Model:           gorm.Model{ID: item.ItemID},
+			Title:           item.Title,
+			Details:         item.Details,
+			ExecutionMethod: item.ExecutionMethod,
+			Type:            item.Type,
+			Unit:            item.Unit,
+			MinValue:        item.MinValue,
+			MaxValue:        item.MaxValue,
+			Options:         item.Options,
+		})
+	}
+	return point, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"go-inspect/models"
+	"testing"
+)
+
+func TestPublishRouteVersion(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 2, models.InspectionItem{Title: "压力", ExecutionMethod: "读表", Type: models.ItemTypeNumeric, MinValue: float(0.2), MaxValue: float(0.6)})
+
+	first, err := PublishRouteVersion(f.route.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := PublishRouteVersion(f.route.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Version != 1 || again.ID != first.ID || again.Checksum != first.Checksum {
+		t.Errorf("unchanged route: versions %d/%d (id %d/%d), want the same version 1", first.Version, again.Version, first.ID, again.ID)
+	}
+
+	// 工单生成时固定当前版本
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.RouteVersionID == nil || *order.RouteVersionID != first.ID {
+		t.Fatalf("order route version = %v, want %d", order.RouteVersionID, first.ID)
+	}
+
+	item := f.points[0].Items[0]
+	if err := db.Model(&item).Update("max_value", 0.8).Error; err != nil {
+		t.Fatal(err)
+	}
+	second, err := PublishRouteVersion(f.route.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Version != 2 || second.ID == first.ID || second.Checksum == first.Checksum {
+		t.Errorf("edited item: version %d checksum %s, want a new version 2", second.Version, second.Checksum)
+	}
+
+	// 已生成的工单仍按原版本的巡检项执行
+	pinned := reload[models.InspectionOrder](t, order.ID)
+	if pinned.RouteVersionID == nil || *pinned.RouteVersionID != first.ID {
+		t.Errorf("pinned order route version = %v, want %d", pinned.RouteVersionID, first.ID)
+	}
+	version, err := orderRouteVersion(db, pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := orderPoint(db, version, f.points[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(point.Items) != 1 || point.Items[0].MaxValue == nil || *point.Items[0].MaxValue != 0.6 {
+		t.Errorf("pinned order items = %+v, want max value 0.6", point.Items)
+	}
+
+	// 修改后生成的工单固定新版本
+	if err := db.Model(&f.plan).Update("last_manual_at", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	next, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RouteVersionID == nil || *next.RouteVersionID != second.ID {
+		t.Errorf("new order route version = %v, want %d", next.RouteVersionID, second.ID)
+	}
+}
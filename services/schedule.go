@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"go-inspect/models"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// PlanLocation 返回计划配置的时区，未配置时使用服务器本地时区
+func PlanLocation(plan models.InspectionPlan) (*time.Location, error) {
+	if plan.TimeZone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(plan.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区: %s", plan.TimeZone)
+	}
+	return loc, nil
+}
+
+// PlanSchedule 根据巡检计划的触发规则构造调度，触发时刻按计划时区计算；手动触发的计划返回 nil
+func PlanSchedule(plan models.InspectionPlan) (cron.Schedule, error) {
+	loc, err := PlanLocation(plan)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := planRule(plan)
+	if err != nil || schedule == nil {
+		return nil, err
+	}
+	return locationSchedule{inner: schedule, loc: loc}, nil
+}
+
+// PlanOccurrences 返回计划在 (after, until] 区间内、且处于有效期内的触发时刻，最多 limit 个；手动触发的计划返回空
+func PlanOccurrences(plan models.InspectionPlan, after, until time.Time, limit int) ([]time.Time, error) {
+	schedule, err := PlanSchedule(plan)
+	if err != nil || schedule == nil {
+		return nil, err
+	}
+
+	// 开始日期当刻的触发也应计入，因此从开始日期前一秒起算
+	if plan.StartDate != nil && !plan.StartDate.Add(-time.Second).Before(after) {
+		after = plan.StartDate.Add(-time.Second)
+	}
+	if plan.EndDate != nil && plan.EndDate.Before(until) {
+		until = *plan.EndDate
+	}
+	return Occurrences(schedule, after, until, limit), nil
+}
+
+// PlanExpired 判断计划在 now 时是否已超过结束日期或达到触发次数上限
+func PlanExpired(plan models.InspectionPlan, now time.Time) bool {
+	if plan.EndDate != nil && now.After(*plan.EndDate) {
+		return true
+	}
+	return plan.MaxOccurrences > 0 && plan.OccurrenceCount >= plan.MaxOccurrences
+}
+
+// planAnchor 返回计划调度的起始时刻
+func planAnchor(plan models.InspectionPlan) time.Time {
+	if plan.StartDate != nil {
+		return *plan.StartDate
+	}
+	return plan.CreatedAt
+}
+
+func planRule(plan models.InspectionPlan) (cron.Schedule, error) {
+	switch plan.TriggerType {
+	case models.TriggerTypeMonthly:
+		if plan.TriggerDay < 1 || plan.TriggerDay > 31 {
+			return nil, fmt.Errorf("月触发日必须在1到31之间")
+		}
+		return monthlySchedule{day: plan.TriggerDay}, nil
+	case models.TriggerTypeWeekly:
+		return cronParser.Parse(fmt.Sprintf("0 0 * * %d", plan.TriggerDay))
+	case models.TriggerTypeCron:
+		return ParseCronExpr(plan.CronExpr)
+	case models.TriggerTypeInterval:
+		if plan.IntervalHours <= 0 {
+			return nil, fmt.Errorf("间隔小时数必须大于0")
+		}
+		return intervalSchedule{
+			anchor: planAnchor(plan).Truncate(time.Minute),
+			every:  time.Duration(plan.IntervalHours) * time.Hour,
+		}, nil
+	case models.TriggerTypeManual:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("无效的触发类型")
+	}
+}
+
+// ParseCronExpr 解析标准五段式 cron 表达式，日字段支持 L 表示每月最后一天
+func ParseCronExpr(expr string) (cron.Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("cron 表达式不能为空")
+	}
+
+	lastDay := false
+	if len(fields) == 5 && strings.EqualFold(fields[2], "L") {
+		fields[2] = "*"
+		lastDay = true
+	}
+
+	schedule, err := cronParser.Parse(strings.Join(fields, " "))
+	if err != nil {
+		return nil, fmt.Errorf("无效的 cron 表达式: %v", err)
+	}
+	if lastDay {
+		return lastDayOfMonthSchedule{inner: schedule}, nil
+	}
+	return schedule, nil
+}
+
+// Occurrences 返回 (after, until] 区间内的触发时刻，最多 limit 个
+func Occurrences(schedule cron.Schedule, after, until time.Time, limit int) []time.Time {
+	var occurrences []time.Time
+	for next := schedule.Next(after); !next.IsZero() && !next.After(until); next = schedule.Next(next) {
+		if len(occurrences) >= limit {
+			break
+		}
+		occurrences = append(occurrences, next)
+	}
+	return occurrences
+}
+
+// locationSchedule 在指定时区内计算内层调度的触发时刻
+type locationSchedule struct {
+	inner cron.Schedule
+	loc   *time.Location
+}
+
+func (s locationSchedule) Next(t time.Time) time.Time {
+	return s.inner.Next(t.In(s.loc)).In(s.loc)
+}
+
+// monthlySchedule 每月指定日零点触发，超出当月天数时取当月最后一天
+type monthlySchedule struct {
+	day int
+}
+
+func (s monthlySchedule) Next(t time.Time) time.Time {
+	year, month, _ := t.Date()
+	for i := 0; i < 2; i++ {
+		candidate := time.Date(year, month+time.Month(i), 1, 0, 0, 0, 0, t.Location())
+		lastDay := candidate.AddDate(0, 1, -1).Day()
+		candidate = candidate.AddDate(0, 0, min(s.day, lastDay)-1)
+		if candidate.After(t) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+// intervalSchedule 从锚点时刻起每隔固定时长触发一次
+type intervalSchedule struct {
+	anchor time.Time
+	every  time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.anchor) {
+		return s.anchor
+	}
+	n := t.Sub(s.anchor)/s.every + 1
+	return s.anchor.Add(n * s.every)
+}
+
+// lastDayOfMonthSchedule 只保留落在当月最后一天的触发时刻
+type lastDayOfMonthSchedule struct {
+	inner cron.Schedule
+}
+
+func (s lastDayOfMonthSchedule) Next(t time.Time) time.Time {
+	// 内层调度每天都会触发，最多向后查找约一个月即可命中
+	for i := 0; i < 24*60*32; i++ {
+		t = s.inner.Next(t)
+		if t.IsZero() || t.AddDate(0, 0, 1).Day() == 1 {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"go-inspect/models"
+	"testing"
+	"time"
+)
+
+func TestParseCronExpr(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"0 8 * * 1-5", false},
+		{"*/15 * * * *", false},
+		{"30 18 L * *", false},
+		{"30 18 l * *", false},
+		{"@daily", false},
+		{"", true},
+		{"* * *", true},
+		{"61 * * * *", true},
+		{"0 8 L L *", true},
+	}
+	for _, tt := range tests {
+		_, err := ParseCronExpr(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCronExpr(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronLastDayOfMonth(t *testing.T) {
+	schedule, err := ParseCronExpr("30 18 L * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, want time.Time
+	}{
+		{date(2024, 1, 15, 0, 0), date(2024, 1, 31, 18, 30)},
+		{date(2024, 1, 31, 18, 30), date(2024, 2, 29, 18, 30)},
+		{date(2025, 2, 1, 0, 0), date(2025, 2, 28, 18, 30)},
+		{date(2025, 4, 30, 19, 0), date(2025, 5, 31, 18, 30)},
+		{date(2025, 12, 31, 20, 0), date(2026, 1, 31, 18, 30)},
+	}
+	for _, tt := range tests {
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestMonthlyScheduleClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		day   int
+		after time.Time
+		until time.Time
+		want  []time.Time
+	}{
+		{
+			day:   31,
+			after: date(2025, 1, 1, 0, 0),
+			until: date(2025, 5, 1, 0, 0),
+			want:  []time.Time{date(2025, 1, 31, 0, 0), date(2025, 2, 28, 0, 0), date(2025, 3, 31, 0, 0), date(2025, 4, 30, 0, 0)},
+		},
+		{
+			day:   30,
+			after: date(2024, 1, 30, 0, 0),
+			until: date(2024, 3, 31, 0, 0),
+			want:  []time.Time{date(2024, 2, 29, 0, 0), date(2024, 3, 30, 0, 0)},
+		},
+		{
+			day:   1,
+			after: date(2024, 12, 15, 0, 0),
+			until: date(2025, 2, 1, 0, 0),
+			want:  []time.Time{date(2025, 1, 1, 0, 0), date(2025, 2, 1, 0, 0)},
+		},
+	}
+	for _, tt := range tests {
+		plan := models.InspectionPlan{TriggerType: models.TriggerTypeMonthly, TriggerDay: tt.day, TimeZone: "UTC"}
+		got, err := PlanOccurrences(plan, tt.after, tt.until, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertTimes(t, got, tt.want)
+	}
+}
+
+func TestIntervalSchedule(t *testing.T) {
+	schedule := intervalSchedule{anchor: date(2025, 1, 1, 8, 0), every: 6 * time.Hour}
+	tests := []struct {
+		from, want time.Time
+	}{
+		{date(2025, 1, 1, 7, 0), date(2025, 1, 1, 8, 0)},
+		{date(2025, 1, 1, 8, 0), date(2025, 1, 1, 14, 0)},
+		{date(2025, 1, 1, 9, 30), date(2025, 1, 1, 14, 0)},
+		{date(2025, 1, 2, 2, 0), date(2025, 1, 2, 8, 0)},
+	}
+	for _, tt := range tests {
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestPlanOccurrencesTimeZoneAndWindow(t *testing.T) {
+	start := date(2025, 3, 2, 0, 0)
+	end := date(2025, 3, 20, 0, 0)
+	plan := models.InspectionPlan{
+		TriggerType: models.TriggerTypeWeekly,
+		TriggerDay:  1, // 周一
+		TimeZone:    "Asia/Shanghai",
+		StartDate:   &start,
+		EndDate:     &end,
+	}
+	got, err := PlanOccurrences(plan, date(2025, 1, 1, 0, 0), date(2025, 12, 31, 0, 0), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 上海时间周一零点即 UTC 周日 16:00，且只保留有效期内的触发
+	assertTimes(t, got, []time.Time{date(2025, 3, 2, 16, 0), date(2025, 3, 9, 16, 0), date(2025, 3, 16, 16, 0)})
+
+	if got, err := PlanOccurrences(models.InspectionPlan{TriggerType: models.TriggerTypeManual}, start, end, 100); err != nil || len(got) != 0 {
+		t.Errorf("manual plan occurrences = %v, %v; want none", got, err)
+	}
+	if _, err := PlanOccurrences(models.InspectionPlan{TriggerType: models.TriggerTypeWeekly, TimeZone: "Mars/Olympus"}, start, end, 100); err == nil {
+		t.Error("expected error for invalid time zone")
+	}
+}
+
+func TestOccurrencesLimit(t *testing.T) {
+	schedule, err := ParseCronExpr("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Occurrences(schedule, date(2025, 1, 1, 0, 0), date(2025, 1, 2, 0, 0), 3)
+	assertTimes(t, got, []time.Time{date(2025, 1, 1, 0, 1), date(2025, 1, 1, 0, 2), date(2025, 1, 1, 0, 3)})
+}
+
+func TestPlanExpired(t *testing.T) {
+	end := date(2025, 6, 1, 0, 0)
+	now := date(2025, 5, 1, 0, 0)
+	tests := []struct {
+		name string
+		plan models.InspectionPlan
+		want bool
+	}{
+		{"no limits", models.InspectionPlan{}, false},
+		{"before end date", models.InspectionPlan{EndDate: &end}, false},
+		{"below max occurrences", models.InspectionPlan{MaxOccurrences: 3, OccurrenceCount: 2}, false},
+		{"reached max occurrences", models.InspectionPlan{MaxOccurrences: 3, OccurrenceCount: 3}, true},
+	}
+	for _, tt := range tests {
+		if got := PlanExpired(tt.plan, now); got != tt.want {
+			t.Errorf("%s: PlanExpired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !PlanExpired(models.InspectionPlan{EndDate: &end}, end.Add(time.Second)) {
+		t.Error("plan should expire after its end date")
+	}
+}
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func assertTimes(t *testing.T, got, want []time.Time) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d times %v, want %d %v", len(got), got, len(want), want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("time %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
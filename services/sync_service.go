@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/config"
+	"go-inspect/models"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SyncOperationType 离线同步的操作类型
+type SyncOperationType string
+
+const (
+	SyncOperationStart    SyncOperationType = "start"
+	SyncOperationCheck    SyncOperationType = "check"
+	SyncOperationComplete SyncOperationType = "complete"
+)
+
+// maxClockSkew 客户端时间晚于服务端超过该时长时以服务端时间为准
+const maxClockSkew = 5 * time.Minute
+
+// SyncOperationInput 离线期间排队的一次操作
+type SyncOperationInput struct {
+	ID             string            `json:"id" binding:"required,max=64"` // 客户端生成的幂等ID
+	Type           SyncOperationType `json:"type" binding:"required"`
+	OrderID        uint              `json:"order_id" binding:"required"`
+	PointID        uint              `json:"point_id"`
+	ClientTime     time.Time         `json:"client_time"` // 客户端记录的操作时间
+	Version        int               `json:"version"`     // 客户端持有的工单版本号，非 0 时必须与当前版本一致
+	Check          *CheckInput       `json:"check"`
+	InspectionData string            `json:"inspection_data"`
+}
+
+// SyncResult 单个操作的处理结果
+type SyncResult struct {
+	ID           string            `json:"id"`
+	Status       models.SyncStatus `json:"status"`
+	Message      string            `json:"message,omitempty"`
+	OrderVersion int               `json:"order_version,omitempty"`
+	Duplicate    bool              `json:"duplicate,omitempty"` // 该操作此前已处理过，返回首次处理的结果
+}
+
+// SyncActor 提交同步的用户
+type SyncActor struct {
+	UserID     uint
+	UserRole   string
+	ProjectIDs []uint // 有权访问的项目
+}
+
+var (
+	// ErrOrderReassigned 工单已改派给其他执行人
+	ErrOrderReassigned = errors.New("巡检工单已改派给其他执行人")
+	// ErrInvalidSyncOperation 同步操作的类型或参数不合法
+	ErrInvalidSyncOperation = errors.New("同步操作不合法")
+
+	errSyncSkipped = errors.New("同一工单的前序操作未执行成功，已跳过")
+)
+
+// ApplySyncBatch 按提交顺序依次执行离线操作并逐个返回结果；
+// 同一工单的某个操作未执行成功时，该工单后续的操作不再执行
+func ApplySyncBatch(actor SyncActor, ops []SyncOperationInput) []SyncResult {
+	results := make([]SyncResult, 0, len(ops))
+	failedOrders := make(map[uint]bool)
+	var lastAt time.Time // 本批已执行操作的最晚时间，后续操作的时间不能早于它
+	for _, op := range ops {
+		record := models.SyncOperation{
+			UserID:   actor.UserID,
+			ClientID: op.ID,
+			Type:     string(op.Type),
+			OrderID:  op.OrderID,
+			PointID:  op.PointID,
+			Status:   models.SyncStatusPending,
+		}
+		if !op.ClientTime.IsZero() {
+			record.ClientTime = &op.ClientTime
+		}
+		// 先占用操作ID再执行，并发的重复提交只有一个能执行该操作
+		reserved, err := reserveSyncOperation(&record)
+		if err != nil {
+			log.Printf("记录同步操作 %s 失败: %v", op.ID, err)
+			failedOrders[op.OrderID] = true
+			results = append(results, SyncResult{ID: op.ID, Status: models.SyncStatusFailed, Message: "服务器处理失败，请稍后重试"})
+			continue
+		}
+		if !reserved {
+			var existing models.SyncOperation
+			if err := config.DB.Where("user_id = ? AND client_id = ?", actor.UserID, op.ID).First(&existing).Error; err != nil {
+				log.Printf("读取同步操作 %s 失败: %v", op.ID, err)
+				existing.Status = models.SyncStatusFailed
+			}
+			if existing.Status == models.SyncStatusPending {
+				existing.Message = "该操作正在处理中"
+			}
+			results = append(results, SyncResult{ID: op.ID, Status: existing.Status, Message: existing.Message, OrderVersion: existing.OrderVersion, Duplicate: true})
+			if existing.Status != models.SyncStatusApplied {
+				failedOrders[op.OrderID] = true
+			}
+			continue
+		}
+
+		var order *models.InspectionOrder
+		err = errSyncSkipped
+		if !failedOrders[op.OrderID] {
+			order, err = applySyncOperation(actor, op, &lastAt)
+		}
+		result := SyncResult{ID: op.ID, Status: models.SyncStatusApplied}
+		if order != nil {
+			result.OrderVersion = order.Version
+		}
+		if err != nil {
+			failedOrders[op.OrderID] = true
+			var ok bool
+			if result.Status, result.Message, ok = classifySyncError(err); !ok {
+				// 服务端错误释放占用的操作ID，客户端可以原样重试
+				log.Printf("同步操作 %s 执行失败: %v", op.ID, err)
+				if err := config.DB.Delete(&record).Error; err != nil {
+					log.Printf("释放同步操作 %s 失败: %v", op.ID, err)
+				}
+				results = append(results, result)
+				continue
+			}
+		}
+
+		// 操作已执行，即使结果写入失败也保留占用，重复提交不会再次执行
+		if err := config.DB.Model(&record).Updates(map[string]interface{}{
+			"status":        result.Status,
+			"message":       result.Message,
+			"order_version": result.OrderVersion,
+		}).Error; err != nil {
+			log.Printf("记录同步操作 %s 的结果失败: %v", op.ID, err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// reserveSyncOperation 插入待执行的同步操作记录，操作ID已存在时返回 false
+func reserveSyncOperation(record *models.SyncOperation) (bool, error) {
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	return result.RowsAffected > 0, result.Error
+}
+
+// applySyncOperation 以客户端记录的时间执行单个操作。客户端时间不能晚于服务端时间，
+// 也不能早于工单创建、开始的时间和本批之前的操作，超出时按边界处理，防止倒填时间规避逾期
+func applySyncOperation(actor SyncActor, op SyncOperationInput, lastAt *time.Time) (*models.InspectionOrder, error) {
+	var order models.InspectionOrder
+	if err := config.DB.Preload("Plan").First(&order, op.OrderID).Error; err != nil {
+		return nil, err
+	}
+	if !slices.Contains(actor.ProjectIDs, order.Plan.ProjectID) {
+		return nil, ErrOrderActorNotAllowed
+	}
+	// 离线期间工单可能已改派给他人
+	if order.AssigneeID == nil || *order.AssigneeID != actor.UserID {
+		return nil, ErrOrderReassigned
+	}
+
+	at := syncOperationTime(op.ClientTime, time.Now(), order, *lastAt)
+	*lastAt = at
+	orderActor := OrderActor{
+		UserID:          actor.UserID,
+		UserRole:        actor.UserRole,
+		ProjectMember:   true,
+		ExpectedVersion: op.Version,
+		At:              at,
+	}
+
+	switch op.Type {
+	case SyncOperationStart:
+		return StartOrder(order.ID, orderActor)
+	case SyncOperationCheck:
+		if op.Check == nil || op.PointID == 0 {
+			return nil, ErrInvalidSyncOperation
+		}
+		input := *op.Check
+		input.ExpectedVersion = op.Version
+		input.CheckedAt = at
+		if _, err := CheckPoint(order.ID, op.PointID, orderActor, input); err != nil {
+			return nil, err
+		}
+		if err := config.DB.First(&order, order.ID).Error; err != nil {
+			return nil, err
+		}
+		return &order, nil
+	case SyncOperationComplete:
+		return CompleteOrder(order.ID, orderActor, op.InspectionData)
+	default:
+		return nil, ErrInvalidSyncOperation
+	}
+}
+
+// syncOperationTime 返回离线操作的生效时间：客户端未记录时间或晚于服务端时间超过 maxClockSkew 时取服务端时间，
+// 早于工单创建时间、开始时间或本批上一个操作的时间 lastAt 时取其中最晚的一个
+func syncOperationTime(clientTime, now time.Time, order models.InspectionOrder, lastAt time.Time) time.Time {
+	at := clientTime
+	if at.IsZero() || at.After(now.Add(maxClockSkew)) {
+		at = now
+	}
+	notBefore := order.CreatedAt
+	if order.StartTime != nil && order.StartTime.After(notBefore) {
+		notBefore = *order.StartTime
+	}
+	if lastAt.After(notBefore) {
+		notBefore = lastAt
+	}
+	if at.Before(notBefore) {
+		at = notBefore
+	}
+	return at
+}
+
+// classifySyncError 将操作失败的原因归为冲突或不合法，服务端错误返回 false
+func classifySyncError(err error) (models.SyncStatus, string, bool) {
+	var transitionErr *OrderTransitionError
+	switch {
+	case errors.Is(err, errSyncSkipped),
+		errors.As(err, &transitionErr),
+		errors.Is(err, ErrOrderVersionConflict),
+		errors.Is(err, ErrOrderReassigned),
+		errors.Is(err, ErrOrderNotInProgress),
+		errors.Is(err, ErrOrderActorNotAllowed):
+		return models.SyncStatusConflict, err.Error(), true
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return models.SyncStatusConflict, "巡检工单不存在", true
+	case errors.Is(err, ErrInvalidSyncOperation),
+		errors.Is(err, ErrInvalidCheckInput),
+		errors.Is(err, ErrCheckNotFound),
+		errors.Is(err, ErrOrderChecksIncomplete):
+		return models.SyncStatusRejected, err.Error(), true
+	default:
+		return models.SyncStatusFailed, "服务器处理失败，请稍后重试", false
+	}
+}
+
+// OfflinePackage 执行人离线巡检所需的数据
+type OfflinePackage struct {
+	GeneratedAt time.Time                `json:"generated_at"`
+	Orders      []models.InspectionOrder `json:"orders"`
+	Routes      []models.InspectionRoute `json:"routes"`
+	Points      []models.InspectionPoint `json:"points"` // 各工单涉及的巡检点及其巡检项，工单固定了路线版本时以 route_version 中的巡检项为准
+}
+
+// BuildOfflinePackage 打包执行人所有未关闭的工单及其路线、巡检点和巡检项
+func BuildOfflinePackage(userID uint) (*OfflinePackage, error) {
+	pkg := OfflinePackage{GeneratedAt: time.Now()}
+	if err := config.DB.Preload("Plan").Preload("RouteVersion").Preload("Checks.Results").
+		Where("assignee_id = ? AND status IN ?", userID, OpenOrderStatuses).
+		Order("scheduled_at").Find(&pkg.Orders).Error; err != nil {
+		return nil, err
+	}
+
+	var routeIDs, pointIDs []uint
+	for _, order := range pkg.Orders {
+		if !slices.Contains(routeIDs, order.Plan.RouteID) {
+			routeIDs = append(routeIDs, order.Plan.RouteID)
+		}
+		for _, check := range order.Checks {
+			if !slices.Contains(pointIDs, check.PointID) {
+				pointIDs = append(pointIDs, check.PointID)
+			}
+		}
+	}
+	if len(routeIDs) > 0 {
+		if err := config.DB.Find(&pkg.Routes, routeIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := LoadRouteStops(config.DB, pkg.Routes); err != nil {
+			return nil, err
+		}
+	}
+	if len(pointIDs) > 0 {
+		if err := config.DB.Preload("Items").Find(&pkg.Points, pointIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &pkg, nil
+}
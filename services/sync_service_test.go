@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-inspect/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestSyncOperationTime(t *testing.T) {
+	now := date(2026, 3, 10, 12, 0)
+	created := date(2026, 3, 10, 8, 0)
+	started := date(2026, 3, 10, 9, 0)
+	order := models.InspectionOrder{Model: gorm.Model{CreatedAt: created}}
+	startedOrder := models.InspectionOrder{Model: gorm.Model{CreatedAt: created}, StartTime: &started}
+
+	tests := []struct {
+		name       string
+		clientTime time.Time
+		order      models.InspectionOrder
+		lastAt     time.Time
+		want       time.Time
+	}{
+		{"client time kept", date(2026, 3, 10, 10, 0), order, time.Time{}, date(2026, 3, 10, 10, 0)},
+		{"no client time", time.Time{}, order, time.Time{}, now},
+		{"within clock skew", now.Add(maxClockSkew), order, time.Time{}, now.Add(maxClockSkew)},
+		{"beyond clock skew", now.Add(maxClockSkew + time.Second), order, time.Time{}, now},
+		{"before order created", date(2026, 3, 9, 23, 0), order, time.Time{}, created},
+		{"before order started", date(2026, 3, 10, 8, 30), startedOrder, time.Time{}, started},
+		{"before earlier operation", date(2026, 3, 10, 9, 30), startedOrder, date(2026, 3, 10, 10, 15), date(2026, 3, 10, 10, 15)},
+		{"after earlier operation", date(2026, 3, 10, 11, 0), startedOrder, date(2026, 3, 10, 10, 15), date(2026, 3, 10, 11, 0)},
+		{"server time before earlier operation", time.Time{}, order, now.Add(time.Minute), now.Add(time.Minute)},
+	}
+	for _, tt := range tests {
+		if got := syncOperationTime(tt.clientTime, now, tt.order, tt.lastAt); !got.Equal(tt.want) {
+			t.Errorf("%s: syncOperationTime = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifySyncError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status models.SyncStatus
+		ok     bool
+	}{
+		{errSyncSkipped, models.SyncStatusConflict, true},
+		{&OrderTransitionError{Action: OrderActionStart, From: models.OrderStatusCompleted}, models.SyncStatusConflict, true},
+		{ErrOrderVersionConflict, models.SyncStatusConflict, true},
+		{ErrOrderReassigned, models.SyncStatusConflict, true},
+		{ErrOrderNotInProgress, models.SyncStatusConflict, true},
+		{ErrSelfReview, models.SyncStatusConflict, true},
+		{gorm.ErrRecordNotFound, models.SyncStatusConflict, true},
+		{ErrInvalidSyncOperation, models.SyncStatusRejected, true},
+		{ErrTagMismatch, models.SyncStatusRejected, true},
+		{ErrCheckNotFound, models.SyncStatusRejected, true},
+		{ErrOrderChecksIncomplete, models.SyncStatusRejected, true},
+		{fmt.Errorf("保存失败: %w", ErrOrderChecksIncomplete), models.SyncStatusRejected, true},
+		{errors.New("connection refused"), models.SyncStatusFailed, false},
+	}
+	for _, tt := range tests {
+		status, message, ok := classifySyncError(tt.err)
+		if status != tt.status || ok != tt.ok {
+			t.Errorf("classifySyncError(%v) = %s, %v, want %s, %v", tt.err, status, ok, tt.status, tt.ok)
+		}
+		if message == "" {
+			t.Errorf("classifySyncError(%v) returned an empty message", tt.err)
+		}
+	}
+}
+
+// syncStatuses 取出各操作的处理结果
+func syncStatuses(results []SyncResult) []models.SyncStatus {
+	statuses := make([]models.SyncStatus, 0, len(results))
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestApplySyncBatch(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 2)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actor := SyncActor{UserID: f.assignee.ID, UserRole: f.assignee.Role, ProjectIDs: []uint{f.project.ID}}
+
+	early := order.CreatedAt.Add(-time.Hour)
+	ops := []SyncOperationInput{
+		{ID: "op-1", Type: SyncOperationStart, OrderID: order.ID, ClientTime: early},
+		{ID: "op-2", Type: SyncOperationCheck, OrderID: order.ID, PointID: f.points[0].ID, ClientTime: early.Add(time.Minute), Check: &CheckInput{Status: models.CheckStatusPassed}},
+		{ID: "op-3", Type: SyncOperationCheck, OrderID: order.ID, PointID: f.points[1].ID, Check: &CheckInput{Status: "unknown"}},
+		{ID: "op-4", Type: SyncOperationComplete, OrderID: order.ID},
+		{ID: "op-5", Type: SyncOperationStart, OrderID: other.ID},
+	}
+	want := []models.SyncStatus{models.SyncStatusApplied, models.SyncStatusApplied, models.SyncStatusRejected, models.SyncStatusConflict, models.SyncStatusApplied}
+
+	results := ApplySyncBatch(actor, ops)
+	if got := syncStatuses(results); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("statuses = %v, want %v", got, want)
+	}
+	if results[3].Message != errSyncSkipped.Error() {
+		t.Errorf("later operation on the failed order: message = %q, want skipped", results[3].Message)
+	}
+
+	// 倒填的客户端时间按工单创建时间处理
+	synced := reload[models.InspectionOrder](t, order.ID)
+	if synced.StartTime == nil || !synced.StartTime.Equal(synced.CreatedAt) {
+		t.Errorf("start time = %v, want order creation %v", synced.StartTime, synced.CreatedAt)
+	}
+	var check models.InspectionPointCheck
+	if err := db.Where("order_id = ? AND point_id = ?", order.ID, f.points[0].ID).First(&check).Error; err != nil {
+		t.Fatal(err)
+	}
+	if check.CheckedAt == nil || check.CheckedAt.Before(*synced.StartTime) {
+		t.Errorf("checked at = %v, want not before start %v", check.CheckedAt, synced.StartTime)
+	}
+
+	// 重新提交同一批操作返回首次处理的结果，不会再次执行
+	replay := ApplySyncBatch(actor, ops)
+	if got := syncStatuses(replay); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("replayed statuses = %v, want %v", got, want)
+	}
+	for _, result := range replay {
+		if !result.Duplicate {
+			t.Errorf("replayed %s: Duplicate = false", result.ID)
+		}
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.Version != synced.Version {
+		t.Errorf("order version after replay = %d, want %d", got.Version, synced.Version)
+	}
+	if replay[1].OrderVersion != results[1].OrderVersion {
+		t.Errorf("replayed order version = %d, want %d", replay[1].OrderVersion, results[1].OrderVersion)
+	}
+
+	var pending int64
+	if err := db.Model(&models.SyncOperation{}).Where("status = ?", models.SyncStatusPending).Count(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("%d operations left pending", pending)
+	}
+}
+
+func TestApplySyncBatchInProgressDuplicate(t *testing.T) {
+	db := setupTestDB(t)
+	f := newTestFixture(t, db, 1)
+	order, err := TriggerPlanManually(f.plan.ID, f.assigner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actor := SyncActor{UserID: f.assignee.ID, ProjectIDs: []uint{f.project.ID}}
+
+	// 另一个请求已占用该操作ID但尚未执行完
+	record := models.SyncOperation{UserID: actor.UserID, ClientID: "op-1", Type: string(SyncOperationStart), OrderID: order.ID, Status: models.SyncStatusPending}
+	if reserved, err := reserveSyncOperation(&record); err != nil || !reserved {
+		t.Fatalf("reserve = %v, %v", reserved, err)
+	}
+	results := ApplySyncBatch(actor, []SyncOperationInput{
+		{ID: "op-1", Type: SyncOperationStart, OrderID: order.ID},
+		{ID: "op-2", Type: SyncOperationComplete, OrderID: order.ID},
+	})
+	if !results[0].Duplicate || results[0].Status != models.SyncStatusPending || results[0].Message == "" {
+		t.Errorf("in-flight duplicate = %+v, want pending duplicate", results[0])
+	}
+	if results[1].Status != models.SyncStatusConflict {
+		t.Errorf("operation after in-flight duplicate = %s, want conflict", results[1].Status)
+	}
+	if got := reload[models.InspectionOrder](t, order.ID); got.Status != models.OrderStatusAssigned {
+		t.Errorf("order status = %s, want assigned", got.Status)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"go-inspect/config"
+	"go-inspect/models"
+	"go-inspect/utils"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// tagCodeAlphabet 标签码使用的字符，去掉了易混淆的 I、L、O、U
+	tagCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	tagCodeLength   = 12
+)
+
+var (
+	// ErrTagRequired 确认巡检点时未携带扫描的标签
+	ErrTagRequired = fmt.Errorf("%w：请扫描巡检点的标签", ErrInvalidCheckInput)
+	// ErrTagMismatch 扫描的标签与巡检点不符
+	ErrTagMismatch = fmt.Errorf("%w：扫描的标签与巡检点不符", ErrInvalidCheckInput)
+	// ErrTagExpired 限时标签已过期
+	ErrTagExpired = fmt.Errorf("%w：标签已过期，请重新扫描", ErrInvalidCheckInput)
+	// ErrTagNotSigned 要求限时签名标签时扫描了静态标签
+	ErrTagNotSigned = fmt.Errorf("%w：请扫描巡检点显示的限时标签", ErrInvalidCheckInput)
+)
+
+// NewTagCode 生成随机的巡检点标签码
+func NewTagCode() (string, error) {
+	buf := make([]byte, tagCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, tagCodeLength)
+	for i, b := range buf {
+		code[i] = tagCodeAlphabet[int(b)%len(tagCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// BackfillTagCodes 为尚未生成标签码的巡检点补充标签码
+func BackfillTagCodes() error {
+	var points []models.InspectionPoint
+	if err := config.DB.Where("tag_code IS NULL OR tag_code = ''").Find(&points).Error; err != nil {
+		return err
+	}
+	for _, point := range points {
+		code, err := NewTagCode()
+		if err != nil {
+			return err
+		}
+		if err := config.DB.Model(&point).Update("tag_code", code).Error; err != nil {
+			return err
+		}
+	}
+	if len(points) > 0 {
+		log.Printf("已为 %d 个巡检点生成标签码", len(points))
+	}
+	return nil
+}
+
+// TagPayloadTTL 返回限时签名标签的有效期
+func TagPayloadTTL() time.Duration {
+	if ttl := viper.GetDuration("inspection.tag.payload_ttl"); ttl > 0 {
+		return ttl
+	}
+	return 5 * time.Minute
+}
+
+// TagDeviceRole 返回现场电子屏、NFC 写入器等设备账号的用户角色，只有该角色和管理员可以获取限时签名标签
+func TagDeviceRole() string {
+	if role := viper.GetString("inspection.tag.device_role"); role != "" {
+		return role
+	}
+	return "tag_device"
+}
+
+// SignTagPayload 生成到 expires 为止有效的签名标签内容，格式为 标签码.过期时间.签名，
+// 用于电子墨水屏、可写 NFC 标签等动态显示的场景
+func SignTagPayload(code string, expires time.Time) string {
+	return fmt.Sprintf("%s.%d.%s", code, expires.Unix(), utils.SignResource(tagResource(code), expires))
+}
+
+func tagResource(code string) string {
+	return "tag:" + code
+}
+
+// VerifyTagScan 校验确认巡检点时扫描的标签内容：静态标签为标签码本身，限时标签还需校验签名和有效期。
+// receivedAt 为服务端收到确认的时间，客户端上报的扫描时间不可信，签名标签只额外容忍 maxClockSkew 的时钟偏差
+func VerifyTagScan(point models.InspectionPoint, payload string, receivedAt time.Time) error {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		if tagRequired() {
+			return ErrTagRequired
+		}
+		return nil
+	}
+
+	parts := strings.Split(payload, ".")
+	code := strings.ToUpper(parts[0])
+	if point.TagCode == nil || code != *point.TagCode {
+		return ErrTagMismatch
+	}
+	switch len(parts) {
+	case 1:
+		if viper.GetBool("inspection.tag.require_signed") {
+			return ErrTagNotSigned
+		}
+		return nil
+	case 3:
+		unix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return ErrTagMismatch
+		}
+		expires := time.Unix(unix, 0)
+		if !hmac.Equal([]byte(utils.SignResource(tagResource(code), expires)), []byte(parts[2])) {
+			return ErrTagMismatch
+		}
+		if receivedAt.After(expires.Add(maxClockSkew)) {
+			return ErrTagExpired
+		}
+		return nil
+	default:
+		return ErrTagMismatch
+	}
+}
+
+// tagRequired 确认巡检点时是否必须扫描标签，默认必须
+func tagRequired() bool {
+	if !viper.IsSet("inspection.tag.required") {
+		return true
+	}
+	return viper.GetBool("inspection.tag.required")
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"go-inspect/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestNewTagCode(t *testing.T) {
+	code, err := NewTagCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != tagCodeLength {
+		t.Errorf("len(code) = %d, want %d", len(code), tagCodeLength)
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(tagCodeAlphabet, r) {
+			t.Errorf("code %s contains %q outside the alphabet", code, r)
+		}
+	}
+}
+
+func TestVerifyTagScan(t *testing.T) {
+	viper.Set("jwt.secret", "test-secret")
+	defer viper.Set("jwt.secret", nil)
+
+	code := "7K3M9QX2HD4P"
+	point := models.InspectionPoint{TagCode: &code}
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Minute)
+	signed := SignTagPayload(code, expires)
+
+	tests := []struct {
+		name       string
+		payload    string
+		receivedAt time.Time
+		want       error
+	}{
+		{"static tag", code, now, nil},
+		{"static tag lower case", " 7k3m9qx2hd4p ", now, nil},
+		{"missing tag", "", now, ErrTagRequired},
+		{"other tag", "ABCDEFGHJKMN", now, ErrTagMismatch},
+		{"signed before expiry", signed, now, nil},
+		{"signed within clock skew", signed, expires.Add(maxClockSkew), nil},
+		{"signed expired", signed, expires.Add(maxClockSkew + time.Second), ErrTagExpired},
+		{"signed with extended expiry", strings.Replace(signed, ".", ".1", 1), now, ErrTagMismatch},
+		{"signed with forged signature", code + "." + strings.Split(signed, ".")[1] + ".00", now, ErrTagMismatch},
+		{"malformed", code + ".1", now, ErrTagMismatch},
+	}
+	for _, tt := range tests {
+		err := VerifyTagScan(point, tt.payload, tt.receivedAt)
+		if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	viper.Set("inspection.tag.require_signed", true)
+	defer viper.Set("inspection.tag.require_signed", nil)
+	if err := VerifyTagScan(point, code, now); !errors.Is(err, ErrTagNotSigned) {
+		t.Errorf("static tag with require_signed: error = %v, want ErrTagNotSigned", err)
+	}
+}
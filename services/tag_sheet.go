@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go-inspect/utils"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// TagLabel 标签打印单中的一个巡检点
+type TagLabel struct {
+	Code string
+	Name string
+}
+
+const (
+	tagSheetColumns = 3
+	tagQuietZone    = 4  // 二维码四周的静区模块数
+	tagModulePixels = 6  // PNG 中每个模块的像素
+	tagGlyphScale   = 2  // PNG 中标签码字符的放大倍数
+	tagCellPadding  = 16 // 单元格之间的间距（像素）
+)
+
+// RenderTagSheetSVG 将巡检点标签排版为可打印的 SVG，每个标签下方标注巡检点名称和标签码
+func RenderTagSheetSVG(labels []TagLabel) ([]byte, error) {
+	codes, cell, err := encodeTagLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	const textHeight = 40
+	cellW, cellH := cell*tagModulePixels+tagCellPadding, cell*tagModulePixels+textHeight+tagCellPadding
+	cols, rows := sheetGrid(len(labels))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, cols*cellW, rows*cellH, cols*cellW, rows*cellH)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="#fff"/>`)
+	for i, label := range labels {
+		x, y := (i%cols)*cellW+tagCellPadding/2, (i/cols)*cellH+tagCellPadding/2
+		fmt.Fprintf(&buf, `<g transform="translate(%d %d) scale(%d)">`, x, y, tagModulePixels)
+		for r, row := range codes[i] {
+			for c, dark := range row {
+				if dark {
+					fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="1" height="1"/>`, c+tagQuietZone, r+tagQuietZone)
+				}
+			}
+		}
+		buf.WriteString(`</g>`)
+
+		center := x + cell*tagModulePixels/2
+		textY := y + cell*tagModulePixels
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14">`, center, textY+14)
+		xml.EscapeText(&buf, []byte(label.Name))
+		fmt.Fprintf(&buf, `</text><text x="%d" y="%d" text-anchor="middle" font-family="monospace" font-size="12">`, center, textY+32)
+		xml.EscapeText(&buf, []byte(label.Code))
+		buf.WriteString(`</text>`)
+	}
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+// RenderTagSheetPNG 将巡检点标签排版为可打印的 PNG，每个标签下方标注标签码
+func RenderTagSheetPNG(labels []TagLabel) ([]byte, error) {
+	codes, cell, err := encodeTagLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	textHeight := (tagGlyphHeight + 4) * tagGlyphScale
+	cellW, cellH := cell*tagModulePixels+tagCellPadding, cell*tagModulePixels+textHeight+tagCellPadding
+	cols, rows := sheetGrid(len(labels))
+
+	img := image.NewGray(image.Rect(0, 0, cols*cellW, rows*cellH))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+	fill := func(x, y, size int) {
+		for dy := 0; dy < size; dy++ {
+			for dx := 0; dx < size; dx++ {
+				img.SetGray(x+dx, y+dy, color.Gray{})
+			}
+		}
+	}
+
+	for i, label := range labels {
+		x, y := (i%cols)*cellW+tagCellPadding/2, (i/cols)*cellH+tagCellPadding/2
+		for r, row := range codes[i] {
+			for c, dark := range row {
+				if dark {
+					fill(x+(c+tagQuietZone)*tagModulePixels, y+(r+tagQuietZone)*tagModulePixels, tagModulePixels)
+				}
+			}
+		}
+
+		// 标签码居中绘制在二维码下方
+		advance := (tagGlyphWidth + 1) * tagGlyphScale
+		textX := x + (cell*tagModulePixels-len(label.Code)*advance)/2
+		textY := y + cell*tagModulePixels
+		for n, ch := range label.Code {
+			glyph, ok := tagGlyphs[ch]
+			if !ok {
+				continue
+			}
+			for gy, line := range glyph {
+				for gx, dot := range line {
+					if dot == '#' {
+						fill(textX+n*advance+gx*tagGlyphScale, textY+gy*tagGlyphScale, tagGlyphScale)
+					}
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeTagLabels 编码各标签的二维码，返回二维码矩阵及含静区的最大边长（模块数）
+func encodeTagLabels(labels []TagLabel) ([][][]bool, int, error) {
+	codes := make([][][]bool, 0, len(labels))
+	cell := 0
+	for _, label := range labels {
+		code, err := utils.QRCode(label.Code)
+		if err != nil {
+			return nil, 0, err
+		}
+		codes = append(codes, code)
+		cell = max(cell, len(code)+2*tagQuietZone)
+	}
+	return codes, cell, nil
+}
+
+func sheetGrid(n int) (int, int) {
+	cols := min(max(n, 1), tagSheetColumns)
+	rows := max((n+cols-1)/cols, 1)
+	return cols, rows
+}
+
+const (
+	tagGlyphWidth  = 5
+	tagGlyphHeight = 7
+)
+
+// tagGlyphs 标签码字符的 5x7 点阵
+var tagGlyphs = map[rune][tagGlyphHeight]string{
+	'0': {".###.", "#...#", "#..##", "#.#.#", "##..#", "#...#", ".###."},
+	'1': {"..#..", ".##..", "..#..", "..#..", "..#..", "..#..", ".###."},
+	'2': {".###.", "#...#", "....#", "...#.", "..#..", ".#...", "#####"},
+	'3': {"#####", "...#.", "..#..", "...#.", "....#", "#...#", ".###."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'5': {"#####", "#....", "####.", "....#", "....#", "#...#", ".###."},
+	'6': {"..##.", ".#...", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'8': {".###.", "#...#", "#...#", ".###.", "#...#", "#...#", ".###."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "...#.", ".##.."},
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"###..", "#..#.", "#...#", "#...#", "#...#", "#..#.", "###.."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".####"},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#"},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'Q': {".###.", "#...#", "#...#", "#...#", "#.#.#", "#..#.", ".##.#"},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'S': {".####", "#....", "#....", ".###.", "....#", "....#", "####."},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "#.#.#", ".#.#."},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'Z': {"#####", "....#", "...#.", "..#..", ".#...", "#....", "#####"},
+}
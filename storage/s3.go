@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+)
+
+// ObjectClient S3 兼容的对象存储客户端需要提供的操作
+type ObjectClient interface {
+	PutObject(bucket, key string, body io.Reader, size int64, contentType string) error
+	GetObject(bucket, key string) (io.ReadCloser, error)
+	DeleteObject(bucket, key string) error
+}
+
+// objectClient 注册的对象存储客户端
+var objectClient ObjectClient
+
+// RegisterObjectClient 注册 S3 兼容的对象存储客户端，需在 Init 之前调用
+func RegisterObjectClient(client ObjectClient) {
+	objectClient = client
+}
+
+// S3Storage 基于 S3 兼容对象存储的附件存储
+type S3Storage struct {
+	client ObjectClient
+	bucket string
+	prefix string
+}
+
+// NewS3Storage 创建对象存储，所有 key 都加上 prefix 前缀
+func NewS3Storage(client ObjectClient, bucket, prefix string) *S3Storage {
+	return &S3Storage{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	return s.client.PutObject(s.bucket, s.objectKey(key), r, size, contentType)
+}
+
+func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
+	return s.client.GetObject(s.bucket, s.objectKey(key))
+}
+
+func (s *S3Storage) Delete(key string) error {
+	return s.client.DeleteObject(s.bucket, s.objectKey(key))
+}
+
+func (s *S3Storage) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return path.Join(s.prefix, key)
+}
+
+// DirObjectClient 以本地目录模拟的对象存储，每个 bucket 对应根目录下的一个子目录
+type DirObjectClient struct {
+	root string
+}
+
+// NewDirObjectClient 创建以 root 为根目录的本地对象存储
+func NewDirObjectClient(root string) *DirObjectClient {
+	if root == "" {
+		root = "./objects"
+	}
+	return &DirObjectClient{root: root}
+}
+
+func (c *DirObjectClient) PutObject(bucket, key string, body io.Reader, size int64, contentType string) error {
+	return c.bucket(bucket).Put(key, body, size, contentType)
+}
+
+func (c *DirObjectClient) GetObject(bucket, key string) (io.ReadCloser, error) {
+	return c.bucket(bucket).Get(key)
+}
+
+func (c *DirObjectClient) DeleteObject(bucket, key string) error {
+	return c.bucket(bucket).Delete(key)
+}
+
+func (c *DirObjectClient) bucket(bucket string) *LocalStorage {
+	return NewLocalStorage(filepath.Join(c.root, filepath.Base("/"+bucket)))
+}
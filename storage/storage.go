@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 附件存储，key 为以 / 分隔的相对路径
+type Storage interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Default 全局附件存储
+var Default Storage
+
+// Init 按配置初始化全局附件存储
+// storage.driver 为 local 时存放在 storage.local.root 目录；
+// 为 s3 时通过 S3 兼容的对象存储客户端存放在 storage.s3.bucket 中
+func Init() error {
+	switch driver := viper.GetString("storage.driver"); driver {
+	case "", "local":
+		root := viper.GetString("storage.local.root")
+		if root == "" {
+			root = "./uploads"
+		}
+		Default = NewLocalStorage(root)
+	case "s3":
+		bucket := viper.GetString("storage.s3.bucket")
+		if bucket == "" {
+			return fmt.Errorf("未配置 storage.s3.bucket")
+		}
+		client := objectClient
+		if client == nil {
+			// 未注册对象存储客户端时使用本地目录模拟
+			client = NewDirObjectClient(viper.GetString("storage.s3.stand_in_root"))
+		}
+		Default = NewS3Storage(client, bucket, viper.GetString("storage.s3.prefix"))
+	default:
+		return fmt.Errorf("不支持的存储类型: %s", driver)
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package tasks
 
 import (
+	"errors"
 	"go-inspect/config"
 	"go-inspect/models"
+	"go-inspect/services"
+	"log"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -10,12 +13,27 @@ import (
 
 var cronJob *cron.Cron
 
+const (
+	// missedRunGrace 触发时刻早于当前时间超过该时长即视为错过的触发
+	missedRunGrace = 2 * time.Minute
+	// maxCatchUpRuns 单次调度最多补齐的触发次数，其余在后续调度中继续补齐
+	maxCatchUpRuns = 100
+	// maxScannedOccurrences 单次调度最多展开的触发时刻，防止高频规则长时间停机后遍历过久
+	maxScannedOccurrences = 100000
+)
+
 // InitCronJobs 初始化并启动定时任务
 func InitCronJobs() {
 	cronJob = cron.New()
 
-	// 每天凌晨检查并触发巡检计划
-	cronJob.AddFunc("0 0 * * *", triggerInspectionPlans)
+	// 启动时先补偿停机期间错过的触发
+	triggerInspectionPlans()
+
+	// 每分钟检查并按各计划配置的时刻触发巡检计划
+	cronJob.AddFunc("* * * * *", triggerInspectionPlans)
+
+	// 每五分钟检查逾期工单并逐级升级
+	cronJob.AddFunc("*/5 * * * *", escalateOverdueOrders)
 
 	// 启动定时任务
 	cronJob.Start()
@@ -30,59 +48,154 @@ func StopCronJobs() {
 
 func triggerInspectionPlans() {
 	var plans []models.InspectionPlan
-	config.DB.Find(&plans)
+	if err := config.DB.Where("status = ? AND trigger_type <> ?", models.PlanStatusActive, models.TriggerTypeManual).Find(&plans).Error; err != nil {
+		log.Printf("获取巡检计划失败: %v", err)
+		return
+	}
 
 	now := time.Now()
 	for _, plan := range plans {
-		if shouldTriggerPlan(plan, now) {
-			createInspectionOrder(plan)
-			plan.LastTriggeredAt = &now
-			config.DB.Save(&plan)
+		occurrences, cursor := shouldTriggerPlan(plan, now)
+		if !cursor.IsZero() {
+			generated, ok := generateOrders(plan, occurrences)
+			plan.OccurrenceCount += generated
+			if !ok {
+				continue
+			}
+			// 被跳过或工单已存在的触发也要推进调度游标，避免下次重复补偿
+			if plan.LastOccurrence == nil || cursor.After(*plan.LastOccurrence) {
+				if err := config.DB.Model(&models.InspectionPlan{}).Where("id = ?", plan.ID).Update("last_occurrence", cursor).Error; err != nil {
+					log.Printf("巡检计划 %d 更新调度游标失败: %v", plan.ID, err)
+				}
+			}
+		}
+
+		// 有效期结束或达到触发次数上限的计划自动停用
+		if services.PlanExpired(plan, now) {
+			if err := config.DB.Model(&models.InspectionPlan{}).Where("id = ?", plan.ID).Update("status", models.PlanStatusExpired).Error; err != nil {
+				log.Printf("巡检计划 %d 停用失败: %v", plan.ID, err)
+				continue
+			}
+			log.Printf("巡检计划 %d 已到期，自动停用", plan.ID)
 		}
 	}
 }
 
-func createInspectionOrder(plan models.InspectionPlan) {
-	order := models.InspectionOrder{
-		PlanID: plan.ID,
-		Status: models.OrderStatusPending,
+func escalateOverdueOrders() {
+	if err := services.EscalateOverdueOrders(time.Now()); err != nil {
+		log.Printf("检查逾期工单失败: %v", err)
 	}
+}
 
-	if plan.AssignerID != 0 {
-		order.AssignerID = &plan.AssignerID
-		order.Status = models.OrderStatusAssigned
+// generateOrders 依次为各触发时刻生成工单，遇到失败即停止以便下次调度重试，返回新生成的工单数
+func generateOrders(plan models.InspectionPlan, occurrences []time.Time) (int, bool) {
+	generated := 0
+	for _, occurrence := range occurrences {
+		order, err := services.GenerateInspectionOrder(services.OrderRequest{Plan: plan, Occurrence: occurrence, Scheduled: true})
+		switch {
+		case errors.Is(err, services.ErrOrderAlreadyGenerated):
+			log.Printf("巡检计划 %d 在 %s 的工单已存在（工单 %d），跳过", plan.ID, occurrence.Format(time.DateTime), order.ID)
+		case errors.Is(err, services.ErrOccurrenceSkipped):
+			log.Printf("巡检计划 %d 在 %s 的触发为停检日，跳过", plan.ID, occurrence.Format(time.DateTime))
+		case err != nil:
+			log.Printf("巡检计划 %d 生成工单失败: %v", plan.ID, err)
+			return generated, false
+		default:
+			generated++
+			log.Printf("巡检计划 %d 已生成 %s 的工单 %d", plan.ID, occurrence.Format(time.DateTime), order.ID)
+		}
 	}
+	return generated, true
+}
 
-	config.DB.Create(&order)
+// dueRun 一次到期的触发：计划触发时刻及按停检日调整后的执行时刻
+type dueRun struct {
+	occurrence  time.Time
+	scheduledAt time.Time
+}
 
-	// 获取关联的巡检路线
-	var route models.InspectionRoute
-	config.DB.Preload("Points").First(&route, plan.RouteID)
+// shouldTriggerPlan 在计划时区内计算计划自上次处理以来执行时刻已到的触发（不超过结束日期），
+// 按补偿策略返回需要生成工单的触发时刻，以及调度游标应推进到的时刻（无需处理时为零值）
+func shouldTriggerPlan(plan models.InspectionPlan, now time.Time) ([]time.Time, time.Time) {
+	cal, err := services.ProjectCalendar(plan.ProjectID)
+	if err != nil {
+		log.Printf("巡检计划 %d 获取项目日历失败: %v", plan.ID, err)
+		return nil, time.Time{}
+	}
 
-	// 创建巡检点确认记录
-	for _, point := range route.Points {
-		check := models.InspectionPointCheck{
-			OrderID: order.ID,
-			PointID: point.ID,
-			Status:  models.CheckStatusPending,
+	// 提前到上一个工作日的触发需要预先展开之后的触发时刻
+	until := now
+	if plan.BlackoutRule == models.BlackoutRulePreviousWorkday {
+		until = now.AddDate(0, 0, services.MaxBlackoutShiftDays)
+	}
+	occurrences, err := services.PlanOccurrences(plan, planCursor(plan), until, maxScannedOccurrences)
+	if err != nil {
+		log.Printf("巡检计划 %d 的触发规则无效: %v", plan.ID, err)
+		return nil, time.Time{}
+	}
+	return catchUpRuns(plan, occurrences, cal, now)
+}
+
+// catchUpRuns 从按时间排列的触发时刻中找出执行时刻已到的触发，按计划的补偿策略选出需要生成工单的触发时刻，
+// 并返回调度游标应推进到的时刻（无需处理时为零值）
+func catchUpRuns(plan models.InspectionPlan, occurrences []time.Time, cal services.Calendar, now time.Time) ([]time.Time, time.Time) {
+	var due []dueRun
+	var cursor time.Time
+	for _, occurrence := range occurrences {
+		scheduledAt, ok := services.ScheduledTime(plan, occurrence, cal)
+		// 游标只能依次推进，遇到执行时刻未到的触发即停止
+		if ok && scheduledAt.After(now) {
+			break
 		}
-		config.DB.Create(&check)
+		cursor = occurrence
+		if !ok {
+			log.Printf("巡检计划 %d 在 %s 的触发为停检日，跳过", plan.ID, occurrence.Format(time.DateTime))
+			continue
+		}
+		due = append(due, dueRun{occurrence: occurrence, scheduledAt: scheduledAt})
+	}
+	if len(due) == 0 {
+		return nil, cursor
 	}
+	latest := due[len(due)-1]
 
-	// 更新工单的总巡检点数量
-	order.TotalChecks = len(route.Points)
-	config.DB.Save(&order)
+	switch plan.CatchUpPolicy {
+	case models.CatchUpPolicyAll:
+		limit := maxCatchUpRuns
+		if plan.MaxOccurrences > 0 {
+			limit = min(limit, plan.MaxOccurrences-plan.OccurrenceCount)
+		}
+		if limit <= 0 {
+			return nil, time.Time{}
+		}
+		if len(due) > limit {
+			log.Printf("巡检计划 %d 有 %d 次错过的触发，本次补齐前 %d 次", plan.ID, len(due), limit)
+			due = due[:limit]
+			cursor = due[limit-1].occurrence
+		}
+		runs := make([]time.Time, 0, len(due))
+		for _, run := range due {
+			runs = append(runs, run.occurrence)
+		}
+		return runs, cursor
+	case models.CatchUpPolicySkip:
+		if now.Sub(latest.scheduledAt) > missedRunGrace {
+			log.Printf("巡检计划 %d 跳过 %d 次错过的触发", plan.ID, len(due))
+			return nil, cursor
+		}
+		return []time.Time{latest.occurrence}, cursor
+	default:
+		return []time.Time{latest.occurrence}, cursor
+	}
 }
 
-func shouldTriggerPlan(plan models.InspectionPlan, now time.Time) bool {
-	switch plan.TriggerType {
-	case models.TriggerTypeMonthly:
-		return now.Day() == plan.TriggerDay
-	case models.TriggerTypeWeekly:
-		return int(now.Weekday()) == plan.TriggerDay
-	case models.TriggerTypeManual:
-		return false // 手动触发的计划不会自动触发
-	default:
-		return false
+// planCursor 返回计划调度的起点：最近处理的触发时刻，否则为计划创建时间
+func planCursor(plan models.InspectionPlan) time.Time {
+	if plan.LastOccurrence != nil {
+		return *plan.LastOccurrence
+	}
+	if plan.LastTriggeredAt != nil {
+		return *plan.LastTriggeredAt
 	}
+	return plan.CreatedAt
 }
@@ -0,0 +1,129 @@
+package tasks
+
+import (
+	"go-inspect/models"
+	"go-inspect/services"
+	"testing"
+	"time"
+)
+
+func TestCatchUpRuns(t *testing.T) {
+	base := time.Date(2025, 3, 3, 8, 0, 0, 0, time.UTC) // 周一
+	daily := func(n int) []time.Time {
+		times := make([]time.Time, n)
+		for i := range times {
+			times[i] = base.AddDate(0, 0, i)
+		}
+		return times
+	}
+	blackout := services.Calendar{"2025-03-05": true}
+
+	tests := []struct {
+		name        string
+		plan        models.InspectionPlan
+		occurrences []time.Time
+		cal         services.Calendar
+		now         time.Time
+		wantRuns    []time.Time
+		wantCursor  time.Time
+	}{
+		{
+			name:        "once keeps only the latest missed run",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyOnce},
+			occurrences: daily(3),
+			now:         base.AddDate(0, 0, 2).Add(time.Hour),
+			wantRuns:    []time.Time{base.AddDate(0, 0, 2)},
+			wantCursor:  base.AddDate(0, 0, 2),
+		},
+		{
+			name:        "skip runs the latest within the grace period",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicySkip},
+			occurrences: daily(3),
+			now:         base.AddDate(0, 0, 2).Add(time.Minute),
+			wantRuns:    []time.Time{base.AddDate(0, 0, 2)},
+			wantCursor:  base.AddDate(0, 0, 2),
+		},
+		{
+			name:        "skip drops runs older than the grace period",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicySkip},
+			occurrences: daily(3),
+			now:         base.AddDate(0, 0, 2).Add(time.Hour),
+			wantRuns:    nil,
+			wantCursor:  base.AddDate(0, 0, 2),
+		},
+		{
+			name:        "all runs every missed occurrence",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll},
+			occurrences: daily(4),
+			now:         base.AddDate(0, 0, 4),
+			wantRuns:    daily(4),
+			wantCursor:  base.AddDate(0, 0, 3),
+		},
+		{
+			name:        "all stops at the remaining occurrence limit",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll, MaxOccurrences: 5, OccurrenceCount: 3},
+			occurrences: daily(4),
+			now:         base.AddDate(0, 0, 4),
+			wantRuns:    daily(2),
+			wantCursor:  base.AddDate(0, 0, 1),
+		},
+		{
+			name:        "all caps a single pass at maxCatchUpRuns",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll},
+			occurrences: daily(maxCatchUpRuns + 20),
+			now:         base.AddDate(1, 0, 0),
+			wantRuns:    daily(maxCatchUpRuns),
+			wantCursor:  base.AddDate(0, 0, maxCatchUpRuns-1),
+		},
+		{
+			name:        "all does nothing once the occurrence limit is reached",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll, MaxOccurrences: 3, OccurrenceCount: 3},
+			occurrences: daily(2),
+			now:         base.AddDate(0, 0, 2),
+			wantRuns:    nil,
+			wantCursor:  time.Time{},
+		},
+		{
+			name:        "future occurrences are not due",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll},
+			occurrences: daily(3),
+			now:         base.AddDate(0, 0, 1).Add(time.Hour),
+			wantRuns:    daily(2),
+			wantCursor:  base.AddDate(0, 0, 1),
+		},
+		{
+			name:        "skipped blackout days still advance the cursor",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll, BlackoutRule: models.BlackoutRuleSkip},
+			occurrences: daily(3)[2:],
+			cal:         blackout,
+			now:         base.AddDate(0, 0, 3),
+			wantRuns:    nil,
+			wantCursor:  base.AddDate(0, 0, 2),
+		},
+		{
+			name:        "runs shifted past now wait for their new time",
+			plan:        models.InspectionPlan{CatchUpPolicy: models.CatchUpPolicyAll, BlackoutRule: models.BlackoutRuleNextWorkday},
+			occurrences: daily(3),
+			cal:         blackout,
+			now:         base.AddDate(0, 0, 2).Add(time.Hour),
+			wantRuns:    daily(2),
+			wantCursor:  base.AddDate(0, 0, 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, cursor := catchUpRuns(tt.plan, tt.occurrences, tt.cal, tt.now)
+			if len(runs) != len(tt.wantRuns) {
+				t.Fatalf("runs = %v, want %v", runs, tt.wantRuns)
+			}
+			for i := range runs {
+				if !runs[i].Equal(tt.wantRuns[i]) {
+					t.Errorf("run %d = %s, want %s", i, runs[i], tt.wantRuns[i])
+				}
+			}
+			if !cursor.Equal(tt.wantCursor) {
+				t.Errorf("cursor = %s, want %s", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
@@ -25,6 +25,16 @@ func HasProjectAccess(c *gin.Context, projectID uint) bool {
 	return *user.ProjectID == projectID
 }
 
+// IsAdmin 检查当前用户是否为未关联项目的超级管理员
+func IsAdmin(c *gin.Context) bool {
+	userID, _ := c.Get("userId")
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.ProjectID == nil
+}
+
 // GetAccessibleProjectIDs 获取用户有权限访问的所有项目ID
 func GetAccessibleProjectIDs(c *gin.Context) []uint {
 	userID, _ := c.Get("userId")
@@ -87,3 +97,10 @@ func getProjectAndSubprojectIDs(projectID uint) []uint {
 
 	return ids
 }
+
+// CurrentUserID 获取当前登录用户的ID
+func CurrentUserID(c *gin.Context) uint {
+	userID, _ := c.Get("userId")
+	id, _ := userID.(uint)
+	return id
+}
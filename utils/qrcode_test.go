@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 参考矩阵由独立的 QRCode 实现（纠错等级 M，自动选择掩码）生成，# 为深色模块
+var qrReferences = []struct {
+	content string
+	rows    []string
+}{
+	{
+		content: "7K3M9QX2HD4P",
+		rows: []string{
+			"#######..###..#######",
+			"#.....#..#.##.#.....#",
+			"#.###.#.......#.###.#",
+			"#.###.#..#.#..#.###.#",
+			"#.###.#..####.#.###.#",
+			"#.....#.#.....#.....#",
+			"#######.#.#.#.#######",
+			".........##..........",
+			"#..#.##.#..###.#.....",
+			"..#.##...###.#..####.",
+			"##...###..##.#...#..#",
+			"#.####.###..#.##.....",
+			"###.########..#..#.##",
+			"........##.#..#.##.##",
+			"#######...###..##....",
+			"#.....#.####.......#.",
+			"#.###.#...#.###...#..",
+			"#.###.#.#.##..#..####",
+			"#.###.#..#.##.#####.#",
+			"#.....#....#....##...",
+			"#######.##..###....#.",
+		},
+	},
+	{
+		content: "https://inspect.example.com/points/" + strings.Repeat("ABCDEFGHJKMNPQRSTVWXYZ0123456789", 4),
+		rows: []string{
+			"#######..##.##...##.....#..#..#.#..##..#.##...#######",
+			"#.....#..##.###.###.#..###...#...##.###...##..#.....#",
+			"#.###.#.##..#.#..#...#..#....##.###.#...#..#..#.###.#",
+			"#.###.#.#.#.#..####.....##.....#.#..####..#.#.#.###.#",
+			"#.###.#.##.#..###.#.#.#######.#.#..###.####...#.###.#",
+			"#.....#.#..##...##.###..#...##.##....######...#.....#",
+			"#######.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#.#######",
+			"........###.####.######.#...#.#..#.#........#........",
+			"#.#####..#########.....##########...#.#..###..#####..",
+			"#...##..#.#.##.#...###.....#.##.##..##.#####.#.#....#",
+			"..#####..##.#....###....#.....###.####........#.#.#..",
+			"##..#.....#.###.####.#####....#....#.#.....#...###.#.",
+			".#..#.####.#..#...#....##.##.###....###..#.#.####..##",
+			"##..##.##.#....##...##.###..#.##.#..##...###.....#...",
+			".##.#.##.#.##.###..####.####.##.#.#.##.#..#..#.##.#..",
+			"#.###..#.....##..#..#.###..##.###.###..##.#..#####...",
+			"####..#.###...#..#.#...####..#...####....##...###..#.",
+			"..#....##..#....##.#.#..#...####..##.....###....#.##.",
+			".#....##.##..##..##...##..#....#.#.####..##.##..###..",
+			"###.##.###..###.###..#####..#.###.#..#..###.##..##.##",
+			"..###.#.#..#####.#.#..#.....##.....###.#....#..##..##",
+			"..#....#...##..##.#..#.#.#.#..##....#...####.......#.",
+			"#.#..####...#.#.###..#..#.#.##....#..###.####.....#..",
+			"#.#....#...###...##...##.#.####.####.#....###...##...",
+			".#.######.##.#....##.#..######......#.....########...",
+			"#...#...##.#..#.#.#######...#.##....#.....#.#...##.#.",
+			"....#.#.#...########.#.##.#.##.#......####.##.#.#....",
+			".####...##.#####.###..###...#.##.#.#.....#..#...##...",
+			"##.######.##.#.#..###...#####....##.###....#########.",
+			"..#.#....#.#.#...###.#......####.#.#.#.#.##...###..##",
+			"##.##.#...##....#..#....####..#...#.##...#.##..####..",
+			".###...##...##.#.#.##.#.#.#..#.##..#..#..#..#..###..#",
+			"#...#.##.##.#...#.##...#####.....##.#....##.####...#.",
+			"#.#.#.....##...#..#.##.......#...#...#.#.###.#####...",
+			"..#..#####.#.##.###...#.####..#.#.#..#.#..#.##..###..",
+			"###.##...########.#.#....##..#.###.##..##.#.###.##.#.",
+			".#.##.#..#..##.....#....##...#.....###.........##..#.",
+			"........#....#.#..##.#....#####..#.....#.####.######.",
+			"#####.##.###.##...#..#....#.#..#...#####.###....##...",
+			"#.#.##.###..##.#..###...#..#.#####.#.#..###.##.###.#.",
+			".#....#...#.#...#.#..#.##.....#..#.##.##.#....###..##",
+			"#.###..#.#.######.#.#..#.##...#....##..####....##..#.",
+			"##.#####...##.####.##.#....###...#######.###.....##..",
+			".##.....#..####..##..##.####....####.##...##.#.#.#.#.",
+			"...#..#####..#.####....######.##.##.#.#..##.######...",
+			"........#.....##....#..##...#.#..#.#.#.#..###...##.#.",
+			"#######.......####..#.#.#.#.##.##.###.####.##.#.#....",
+			"#.....#.######.##..#.##.#...#.#..#.#.#....###...##..#",
+			"#.###.#.#.#.##..###...#.######.##.#.#....#.########.#",
+			"#.###.#.##...#.#...#.#.###...##.##...#..####.#..##.##",
+			"#.###.#.####..#.#...#.#.#...#.###.#.##.#.#.#.####..##",
+			"#.....#...####..###.###.##....###..##.#..#......##.#.",
+			"#######.##...#...#.#..######.##...####......#........",
+		},
+	},
+}
+
+func TestQRCodeMatchesReference(t *testing.T) {
+	for _, ref := range qrReferences {
+		modules, err := QRCode(ref.content)
+		if err != nil {
+			t.Fatalf("QRCode(%q) error = %v", ref.content, err)
+		}
+		if len(modules) != len(ref.rows) {
+			t.Fatalf("QRCode(%q) size = %d, want %d", ref.content, len(modules), len(ref.rows))
+		}
+		for y, row := range modules {
+			var got strings.Builder
+			for _, dark := range row {
+				if dark {
+					got.WriteByte('#')
+				} else {
+					got.WriteByte('.')
+				}
+			}
+			if got.String() != ref.rows[y] {
+				t.Errorf("QRCode(%q) row %d = %s, want %s", ref.content, y, got.String(), ref.rows[y])
+			}
+		}
+	}
+}
+
+func TestQRCodeVersion(t *testing.T) {
+	tests := []struct {
+		length int
+		size   int
+	}{
+		{0, 21},
+		{14, 21},
+		{15, 25},
+		{106, 41},
+		{107, 45},
+		{180, 53},
+		{181, 57},
+		{213, 57},
+	}
+	for _, tt := range tests {
+		modules, err := QRCode(strings.Repeat("a", tt.length))
+		if err != nil {
+			t.Errorf("QRCode(%d bytes) error = %v", tt.length, err)
+			continue
+		}
+		if len(modules) != tt.size || len(modules[0]) != tt.size {
+			t.Errorf("QRCode(%d bytes) size = %d, want %d", tt.length, len(modules), tt.size)
+		}
+	}
+	if _, err := QRCode(strings.Repeat("a", 214)); !errors.Is(err, ErrQRCodeTooLong) {
+		t.Errorf("QRCode(214 bytes) error = %v, want ErrQRCodeTooLong", err)
+	}
+}
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// 版本 1-M 编码 HELLO WORLD 的数据码字及其纠错码字
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomonRemainder(data, reedSolomonDivisor(len(want))); !slices.Equal(got, want) {
+		t.Errorf("reedSolomonRemainder = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+
+	// 注册 GIF、PNG 解码器
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// MaxImagePixels 允许解码的图片像素上限，防止文件很小但声明尺寸极大的图片耗尽内存
+	MaxImagePixels = 50_000_000
+	// maxSamplesPerAxis 缩小时每个目标像素在每个方向上最多采样的源像素数
+	maxSamplesPerAxis = 4
+)
+
+// ErrImageTooLarge 图片的像素数超过上限
+var ErrImageTooLarge = errors.New("图片尺寸过大")
+
+// CheckImageSize 只读取图片头部的尺寸，像素数超过 MaxImagePixels 时返回 ErrImageTooLarge
+func CheckImageSize(data []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+		return ErrImageTooLarge
+	}
+	return nil
+}
+
+// Thumbnail 将图片按比例缩小到最长边不超过 maxSize，返回 JPEG 编码的缩略图
+func Thumbnail(data []byte, maxSize int) ([]byte, error) {
+	if err := CheckImageSize(data); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	// 按区域平均采样，缩小后比最近邻更平滑；区域较大时只均匀取部分像素，耗时与原图尺寸无关
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		stepY := max(1, (y1-y0)/maxSamplesPerAxis)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+			stepX := max(1, (x1-x0)/maxSamplesPerAxis)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy += stepY {
+				for sx := x0; sx < x1; sx += stepX {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n >> 8)
+			dst.Pix[offset+1] = uint8(g / n >> 8)
+			dst.Pix[offset+2] = uint8(b / n >> 8)
+			dst.Pix[offset+3] = uint8(a / n >> 8)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}